@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"mikrotik-exporter/config"
+)
+
+const checkConfigCommand = "check-config"
+
+// checkConfig validates a config file and prints all problems found. It
+// returns the exit code of the command.
+func checkConfig(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet(checkConfigCommand, flag.ContinueOnError)
+	fs.SetOutput(out)
+	file := fs.String("config-file", "", "config file to check")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if *file == "" {
+		fmt.Fprintln(out, "missing required param config-file")
+		fs.Usage()
+		return 2
+	}
+
+	b, err := ioutil.ReadFile(*file)
+	if err != nil {
+		fmt.Fprintf(out, "could not read config: %v\n", err)
+		return 1
+	}
+
+	c, err := config.Load(bytes.NewReader(b))
+	if err != nil {
+		if verr, ok := err.(*config.ValidationError); ok {
+			for _, p := range verr.Problems {
+				fmt.Fprintf(out, "%s: %s\n", *file, p)
+			}
+			fmt.Fprintf(out, "%d problem(s) found\n", len(verr.Problems))
+			return 1
+		}
+
+		fmt.Fprintf(out, "%s: %v\n", *file, err)
+		return 1
+	}
+
+	fmt.Fprintf(out, "%s: config is valid (%d devices)\n", *file, len(c.Devices))
+	return 0
+}
+
+func isCheckConfig() bool {
+	return len(os.Args) > 1 && os.Args[1] == checkConfigCommand
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"gopkg.in/routeros.v2"
+	"gopkg.in/routeros.v2/proto"
+)
+
+// errScrapeTimeout is returned for commands which would exceed the scrape
+// timeout of a device. Those commands are never sent, so the connection stays
+// usable.
+var errScrapeTimeout = errors.New("scrape timeout exceeded")
+
+// commandRunner runs commands on a device. Besides apiClient, it is
+// implemented by the clients recording and replaying commands.
+type commandRunner interface {
+	Run(sentence ...string) (*routeros.Reply, error)
+}
+
+// apiClient is a synchronous RouterOS API client, which applies a deadline to
+// every command, so a device accepting connections but not answering can not
+// block a scrape. Unlike routeros.Client, it reads the complete reply of
+// failed commands, so the connection stays usable after a !trap.
+type apiClient struct {
+	conn     net.Conn
+	r        proto.Reader
+	w        proto.Writer
+	timeout  time.Duration
+	deadline time.Time
+}
+
+func newAPIClient(conn net.Conn, timeout time.Duration) *apiClient {
+	return &apiClient{
+		conn:    conn,
+		r:       proto.NewReader(conn),
+		w:       proto.NewWriter(conn),
+		timeout: timeout,
+	}
+}
+
+// Close closes the connection to the device
+func (c *apiClient) Close() {
+	c.conn.Close()
+}
+
+// Run sends a command and waits for the reply until the command timeout or
+// the scrape deadline is reached, whichever comes first
+func (c *apiClient) Run(sentence ...string) (*routeros.Reply, error) {
+	deadline := time.Now().Add(c.timeout)
+	if !c.deadline.IsZero() && c.deadline.Before(deadline) {
+		if !time.Now().Before(c.deadline) {
+			return nil, errScrapeTimeout
+		}
+
+		deadline = c.deadline
+	}
+
+	err := c.conn.SetDeadline(deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	c.w.BeginSentence()
+	for _, word := range sentence {
+		c.w.WriteWord(word)
+	}
+	err = c.w.EndSentence()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+// readReply reads sentences up to the !done ending the reply. A !trap is
+// followed by a !done as well, so it is returned after reading the !done. A
+// !fatal is returned immediately, as the device closes the connection.
+func (c *apiClient) readReply() (*routeros.Reply, error) {
+	reply := &routeros.Reply{}
+	var trap error
+
+	for {
+		sen, err := c.r.ReadSentence()
+		if err != nil {
+			return nil, err
+		}
+
+		switch sen.Word {
+		case "!re":
+			reply.Re = append(reply.Re, sen)
+		case "!done":
+			if trap != nil {
+				return nil, trap
+			}
+			reply.Done = sen
+			return reply, nil
+		case "!trap":
+			if trap == nil {
+				trap = &routeros.DeviceError{Sentence: sen}
+			}
+		case "!fatal":
+			return nil, &routeros.DeviceError{Sentence: sen}
+		case "", "!empty":
+			// API docs say that empty sentences should be ignored, RouterOS
+			// 7.18 and later send !empty for replies without items
+		default:
+			return nil, &routeros.UnknownReplyError{Sentence: sen}
+		}
+	}
+}
+
+// isFatal returns whether the device closed the connection after the error
+func isFatal(err error) bool {
+	e, ok := err.(*routeros.DeviceError)
+	return ok && e.Sentence.Word == "!fatal"
+}
+
+// timeoutUntil returns the timeout limited by the deadline, if there is one
+func timeoutUntil(timeout time.Duration, deadline time.Time) time.Duration {
+	if deadline.IsZero() {
+		return timeout
+	}
+
+	if remaining := time.Until(deadline); remaining < timeout {
+		return remaining
+	}
+
+	return timeout
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/routeros.v2"
+	"mikrotik-exporter/collector/routerostest"
+)
+
+func TestRunShouldReadRepliesAfterTrap(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/routing/bgp/peer/print", routerostest.Reply{Trap: "no such command prefix"})
+
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	client := newAPIClient(conn, time.Second)
+	defer client.Close()
+
+	if err := login(client, testUser, testPassword); err != nil {
+		t.Fatalf("could not login: %v", err)
+	}
+
+	_, err = client.Run("/routing/bgp/peer/print")
+	assert.IsType(t, &routeros.DeviceError{}, err)
+
+	reply, err := client.Run("/system/identity/print")
+	if assert.NoError(t, err) && assert.Len(t, reply.Re, 1) {
+		assert.Equal(t, "MikroTik", reply.Re[0].Map["name"])
+	}
+	assert.NotNil(t, reply.Done)
+}
+
+func TestRunShouldIgnoreEmptySentence(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/ip/pool/print", routerostest.Reply{Empty: true})
+
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	client := newAPIClient(conn, time.Second)
+	defer client.Close()
+
+	if err := login(client, testUser, testPassword); err != nil {
+		t.Fatalf("could not login: %v", err)
+	}
+
+	reply, err := client.Run("/ip/pool/print")
+	if assert.NoError(t, err) {
+		assert.Empty(t, reply.Re)
+		assert.NotNil(t, reply.Done)
+	}
+}
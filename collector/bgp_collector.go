@@ -34,6 +34,14 @@ func (c *bgpCollector) init() {
 	}
 }
 
+func (c *bgpCollector) name() string {
+	return "bgp"
+}
+
+func (c *bgpCollector) supportedBy(v routerOSVersion) bool {
+	return capRoutingV6.supportedBy(v)
+}
+
 func (c *bgpCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -86,6 +94,7 @@ func (c *bgpCollector) collectMetricForProperty(property, session, asn string, r
 			"value":    re.Map[property],
 			"error":    err,
 		}).Error("error parsing bgp metric value")
+		ctx.parseError()
 		return
 	}
 
@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+// bgpAddressFamilies maps the address families of RouterOS 7 to the values of
+// the afi label
+var bgpAddressFamilies = []struct {
+	name  string
+	label string
+}{
+	{"ip", "ipv4"},
+	{"ipv6", "ipv6"},
+}
+
+// bgpSessionCollector collects the BGP sessions of RouterOS 7, which replaced
+// /routing/bgp/peer. Metrics shared with bgpCollector keep their names and
+// labels.
+type bgpSessionCollector struct {
+	props              []string
+	upDesc             *prometheus.Desc
+	prefixCountDesc    *prometheus.Desc
+	uptimeDesc         *prometheus.Desc
+	infoDesc           *prometheus.Desc
+	prefixReceivedDesc *prometheus.Desc
+	prefixAdvertDesc   *prometheus.Desc
+}
+
+func newBGPSessionCollector() routerOSCollector {
+	c := &bgpSessionCollector{}
+	c.init()
+	return c
+}
+
+func (c *bgpSessionCollector) init() {
+	c.props = []string{"name", "remote.address", "remote.as", "local.role", "established", "uptime", "prefix-count"}
+
+	const prefix = "bgp"
+	labelNames := []string{"name", "address", "session", "asn"}
+
+	c.upDesc = description(prefix, "up", "BGP session is established (up = 1)", labelNames)
+	c.prefixCountDesc = descriptionForPropertyName(prefix, "prefix-count", labelNames)
+	c.uptimeDesc = description(prefix, "uptime_seconds", "time since the BGP session was established", labelNames)
+	c.infoDesc = description(prefix, "session_info", "BGP session remote address and local role", append(labelNames, "remote_address", "local_role"))
+	c.prefixReceivedDesc = description(prefix, "prefixes_received", "number of prefixes received per address family", append(labelNames, "afi"))
+	c.prefixAdvertDesc = description(prefix, "prefixes_advertised", "number of prefixes advertised per address family", append(labelNames, "afi"))
+}
+
+func (c *bgpSessionCollector) name() string {
+	return "bgp"
+}
+
+func (c *bgpSessionCollector) supportedBy(v routerOSVersion) bool {
+	return capRoutingV7.supportedBy(v)
+}
+
+func (c *bgpSessionCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.upDesc
+	ch <- c.prefixCountDesc
+	ch <- c.uptimeDesc
+	ch <- c.infoDesc
+	ch <- c.prefixReceivedDesc
+	ch <- c.prefixAdvertDesc
+}
+
+func (c *bgpSessionCollector) collect(ctx *collectorContext) error {
+	sessions, err := c.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range sessions {
+		err := c.collectForSession(re, ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *bgpSessionCollector) fetch(ctx *collectorContext) ([]*proto.Sentence, error) {
+	reply, err := ctx.client.Run("/routing/bgp/session/print", "=.proplist="+strings.Join(c.props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching bgp session metrics")
+		return nil, err
+	}
+
+	return reply.Re, nil
+}
+
+func (c *bgpSessionCollector) collectForSession(re *proto.Sentence, ctx *collectorContext) error {
+	session := re.Map["name"]
+	asn := re.Map["remote.as"]
+	labelValues := []string{ctx.device.Name, ctx.device.Address, session, asn}
+
+	up := 0.0
+	if re.Map["established"] == "true" {
+		up = 1
+	}
+	ctx.ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, up, labelValues...)
+	ctx.ch <- prometheus.MustNewConstMetric(c.infoDesc, prometheus.GaugeValue, 1, append(labelValues, re.Map["remote.address"], re.Map["local.role"])...)
+
+	c.collectMetricForProperty(c.prefixCountDesc, "prefix-count", re, labelValues, ctx)
+	if up == 1 {
+		c.collectMetricForProperty(c.uptimeDesc, "uptime", re, labelValues, ctx)
+	}
+
+	for _, afi := range bgpAddressFamilies {
+		received, advertised := 0.0, 0.0
+		if up == 1 {
+			var err error
+			received, err = c.countPrefixes(session, afi.name, "received", ctx, "/routing/route/print", "?afi="+afi.name, "?received-from="+session)
+			if err != nil {
+				return err
+			}
+
+			advertised, err = c.countPrefixes(session, afi.name, "advertised", ctx, "/routing/bgp/advertisements/print", "?afi="+afi.name, "?peer="+session)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx.ch <- prometheus.MustNewConstMetric(c.prefixReceivedDesc, prometheus.GaugeValue, received, append(labelValues, afi.label)...)
+		ctx.ch <- prometheus.MustNewConstMetric(c.prefixAdvertDesc, prometheus.GaugeValue, advertised, append(labelValues, afi.label)...)
+	}
+
+	return nil
+}
+
+func (c *bgpSessionCollector) collectMetricForProperty(desc *prometheus.Desc, property string, re *proto.Sentence, labelValues []string, ctx *collectorContext) {
+	value := re.Map[property]
+	if value == "" {
+		return
+	}
+
+	var v float64
+	var err error
+	if property == "uptime" {
+		v, err = parseDuration(value)
+	} else {
+		v, err = strconv.ParseFloat(value, 64)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device":   ctx.device.Name,
+			"session":  re.Map["name"],
+			"property": property,
+			"value":    value,
+			"error":    err,
+		}).Error("error parsing bgp session metric value")
+		ctx.parseError()
+		return
+	}
+
+	ctx.ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, labelValues...)
+}
+
+// countPrefixes counts the prefixes received from or advertised to the
+// session in the given address family, without reading the prefixes
+func (c *bgpSessionCollector) countPrefixes(session, afi, direction string, ctx *collectorContext, query ...string) (float64, error) {
+	reply, err := ctx.client.Run(append(query, "=count-only=")...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device":    ctx.device.Name,
+			"session":   session,
+			"afi":       afi,
+			"direction": direction,
+			"error":     err,
+		}).Error("error fetching bgp prefix counts")
+		return 0, err
+	}
+
+	v, err := strconv.ParseFloat(reply.Done.Map["ret"], 64)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device":    ctx.device.Name,
+			"session":   session,
+			"afi":       afi,
+			"direction": direction,
+			"error":     err,
+		}).Error("error parsing bgp prefix counts")
+		return 0, err
+	}
+
+	return v, nil
+}
@@ -1,8 +1,8 @@
 package collector
 
 import (
+	"context"
 	"crypto/md5"
-	"crypto/tls"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -19,8 +19,11 @@ import (
 
 const (
 	namespace  = "mikrotik"
-	apiPort    = ":8728"
-	apiPortTLS = ":8729"
+	apiPort    = "8728"
+	apiPortTLS = "8729"
+
+	// connectCollectorName is the collector label of connection failures
+	connectCollectorName = "connect"
 
 	// DefaultTimeout defines the default timeout when connecting to a router
 	DefaultTimeout = 5 * time.Second
@@ -30,29 +33,66 @@ var (
 	scrapeDurationDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "scrape", "collector_duration_seconds"),
 		"mikrotik_exporter: duration of a collector scrape",
-		[]string{"device"},
+		[]string{"device", "collector"},
 		nil,
 	)
 	scrapeSuccessDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "scrape", "collector_success"),
 		"mikrotik_exporter: whether a collector succeeded",
+		[]string{"device", "collector"},
+		nil,
+	)
+	connectionAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "connection", "age_seconds"),
+		"mikrotik_exporter: age of the API connection to the device",
+		[]string{"device"},
+		nil,
+	)
+	connectionReconnectsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "connection", "reconnects_total"),
+		"mikrotik_exporter: number of times the API connection to the device was re-established",
+		[]string{"device"},
+		nil,
+	)
+	deviceBackoffDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "device", "backoff_seconds"),
+		"mikrotik_exporter: time until the exporter tries to connect to the unreachable device again",
+		[]string{"device"},
+		nil,
+	)
+	deviceReachableDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "device", "reachable"),
+		"mikrotik_exporter: whether the device answered the last attempt to connect, also if the login was rejected",
 		[]string{"device"},
 		nil,
 	)
 )
 
 type collector struct {
-	devices     []config.Device
-	collectors  []routerOSCollector
-	timeout     time.Duration
-	enableTLS   bool
-	insecureTLS bool
+	devices           []config.Device
+	collectors        []routerOSCollector
+	deviceFeatures    map[string]config.Features
+	featureCollectors map[config.Features][]routerOSCollector
+	connections       *connectionManager
+	scrapeErrors      *scrapeErrors
+	timeout           time.Duration
+	scrapeTimeout     time.Duration
+	tlsConfig         *config.TLSConfig
+	tlsFlag           bool
+	tlsInsecure       bool
+	recordDir         string
+	replayDir         string
+	pollCtx           context.Context
+	pollInterval      time.Duration
+	polls             *pollCache
+	limiter           *Limiter
+	jitter            time.Duration
 }
 
 // WithBGP enables BGP routing metrics
 func WithBGP() Option {
 	return func(c *collector) {
-		c.collectors = append(c.collectors, newBGPCollector())
+		c.collectors = append(c.collectors, newBGPCollector(), newBGPSessionCollector())
 	}
 }
 
@@ -140,27 +180,123 @@ func WithIPSecPeers() Option {
 	}
 }
 
-// WithOSPFNeighbor enables OSPF neighbor collector metrics
+// WithOSPFNeighbor enables OSPF neighbor, interface and LSA metrics
 func WithOSPFNeighbor() Option {
 	return func(c *collector) {
-		c.collectors = append(c.collectors, newOSPFNeighborCollector())
+		c.collectors = append(c.collectors, newOSPFNeighborCollector(), newOSPFv7NeighborCollector())
+	}
+}
+
+// WithFirewall enables IPv4 firewall rule metrics, optionally only for rules
+// with a comment
+func WithFirewall(commentedOnly bool) Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newFirewallCollector(commentedOnly))
+	}
+}
+
+// WithFirewallV6 enables IPv6 firewall rule metrics, optionally only for rules
+// with a comment
+func WithFirewallV6(commentedOnly bool) Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newFirewallV6Collector(commentedOnly))
+	}
+}
+
+// WithConntrack enables connection tracking metrics. With topN > 0, the
+// connections of the topN source addresses with the most connections are
+// counted as well.
+func WithConntrack(topN int) Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newConntrackCollector(topN))
+	}
+}
+
+// WithHealth enables system health metrics (temperatures, voltages, fans and
+// power supplies)
+func WithHealth() Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newHealthCollector())
+	}
+}
+
+// WithQueue enables simple and tree queue metrics
+func WithQueue() Option {
+	return func(c *collector) {
+		c.collectors = append(c.collectors, newQueueCollector())
 	}
 }
 
-// WithTimeout sets timeout for connecting to router
+// WithTimeout sets timeout for connecting to router, logging in and every API command
 func WithTimeout(d time.Duration) Option {
 	return func(c *collector) {
 		c.timeout = d
 	}
 }
 
-// WithTLS enables TLS
+// WithScrapeTimeout limits the time spent collecting a single device. Collectors
+// not finished in time are reported as failed.
+func WithScrapeTimeout(d time.Duration) Option {
+	return func(c *collector) {
+		c.scrapeTimeout = d
+	}
+}
+
+// WithTLS enables TLS for all devices, optionally without verifying the
+// device certificates. It overrides these settings of the config file, other
+// TLS settings of the config file are kept.
 func WithTLS(insecure bool) Option {
 	return func(c *collector) {
-		c.enableTLS = true
-		if insecure {
-			c.insecureTLS = true
-		}
+		c.tlsFlag = true
+		c.tlsInsecure = insecure
+	}
+}
+
+// WithInsecureTLS skips the verification of device certificates for all
+// devices using TLS, overriding the config file
+func WithInsecureTLS() Option {
+	return func(c *collector) {
+		c.tlsInsecure = true
+	}
+}
+
+// WithRecordDir writes every command sent to a device and the reply received
+// to a file per device in dir
+func WithRecordDir(dir string) Option {
+	return func(c *collector) {
+		c.recordDir = dir
+	}
+}
+
+// WithReplayDir answers commands from the files written by WithRecordDir
+// instead of connecting to the devices
+func WithReplayDir(dir string) Option {
+	return func(c *collector) {
+		c.replayDir = dir
+	}
+}
+
+// WithPolling polls every device in the background on the given interval
+// until ctx is done. Scrapes are answered with the results of the latest poll.
+func WithPolling(ctx context.Context, interval time.Duration) Option {
+	return func(c *collector) {
+		c.pollCtx = ctx
+		c.pollInterval = interval
+	}
+}
+
+// WithLimiter limits the number of devices collected at the same time
+func WithLimiter(l *Limiter) Option {
+	return func(c *collector) {
+		c.limiter = l
+	}
+}
+
+// WithJitter delays the start of each device by a random duration up to d,
+// spreading connections to many devices over time
+func WithJitter(d time.Duration) Option {
+	return func(c *collector) {
+		c.jitter = d
 	}
 }
 
@@ -171,7 +307,7 @@ type Option func(*collector)
 func NewCollector(cfg *config.Config, opts ...Option) (prometheus.Collector, error) {
 	log.WithFields(log.Fields{
 		"numDevices": len(cfg.Devices),
-	}).Info("setting up collector for devices")
+	}).Debug("setting up collector for devices")
 
 	c := &collector{
 		devices: cfg.Devices,
@@ -180,33 +316,187 @@ func NewCollector(cfg *config.Config, opts ...Option) (prometheus.Collector, err
 			newInterfaceCollector(),
 			newResourceCollector(),
 		},
+		deviceFeatures:    make(map[string]config.Features),
+		featureCollectors: make(map[config.Features][]routerOSCollector),
+		connections:       defaultConnectionManager,
+		scrapeErrors:      defaultScrapeErrors,
 	}
 
 	for _, o := range opts {
 		o(c)
 	}
 
+	c.tlsConfig = cfg.TLS
+
+	for i := range cfg.Devices {
+		f := cfg.DeviceFeatures(&cfg.Devices[i])
+		if f == nil {
+			continue
+		}
+
+		c.deviceFeatures[cfg.Devices[i].Name] = *f
+		if _, found := c.featureCollectors[*f]; !found {
+			c.featureCollectors[*f] = collectorsForFeatures(f)
+		}
+	}
+
+	// custom collectors run for all devices, regardless of their features
+	for _, cc := range cfg.CustomCollectors {
+		co := newCustomCollector(cc)
+		c.collectors = append(c.collectors, co)
+
+		for f := range c.featureCollectors {
+			c.featureCollectors[f] = append(c.featureCollectors[f], co)
+		}
+	}
+
+	if c.pollInterval > 0 {
+		c.startPolling()
+	}
+
 	return c, nil
 }
 
+// collectorsForFeatures creates the collectors for a device with its own set
+// of features. Devices sharing the same features share the collectors.
+func collectorsForFeatures(f *config.Features) []routerOSCollector {
+	collectors := []routerOSCollector{
+		newInterfaceCollector(),
+		newResourceCollector(),
+	}
+
+	if f.BGP {
+		collectors = append(collectors, newBGPCollector(), newBGPSessionCollector())
+	}
+
+	if f.Routes {
+		collectors = append(collectors, newRoutesCollector())
+	}
+
+	if f.RoutesV6 {
+		collectors = append(collectors, newRoutesV6Collector())
+	}
+
+	if f.DHCP {
+		collectors = append(collectors, newDHCPCollector())
+	}
+
+	if f.DHCPLeases {
+		collectors = append(collectors, newDHCPLCollector())
+	}
+
+	if f.DHCPv6 {
+		collectors = append(collectors, newDHCPv6Collector())
+	}
+
+	if f.Pool {
+		collectors = append(collectors, newPoolCollector())
+	}
+
+	if f.PoolV6 {
+		collectors = append(collectors, newPoolV6Collector())
+	}
+
+	if f.Optics {
+		collectors = append(collectors, newOpticsCollector())
+	}
+
+	if f.WlanStations {
+		collectors = append(collectors, newWlanSTACollector())
+	}
+
+	if f.WlanInterfaces {
+		collectors = append(collectors, newWlanIFCollector())
+	}
+
+	if f.Monitor {
+		collectors = append(collectors, newMonitorCollector())
+	}
+
+	if f.IPSecPeers {
+		collectors = append(collectors, newIPSecPeersCollector())
+	}
+
+	if f.OSPFNeighbor {
+		collectors = append(collectors, newOSPFNeighborCollector(), newOSPFv7NeighborCollector())
+	}
+
+	if f.Firewall {
+		collectors = append(collectors, newFirewallCollector(f.FirewallCommentedOnly))
+	}
+
+	if f.FirewallV6 {
+		collectors = append(collectors, newFirewallV6Collector(f.FirewallCommentedOnly))
+	}
+
+	if f.Conntrack {
+		collectors = append(collectors, newConntrackCollector(f.ConntrackTopN))
+	}
+
+	if f.Health {
+		collectors = append(collectors, newHealthCollector())
+	}
+
+	if f.Queue {
+		collectors = append(collectors, newQueueCollector())
+	}
+
+	return collectors
+}
+
+// collectorsForDevice returns the collectors enabled for a device
+func (c *collector) collectorsForDevice(d *config.Device) []routerOSCollector {
+	if f, found := c.deviceFeatures[d.Name]; found {
+		return c.featureCollectors[f]
+	}
+
+	return c.collectors
+}
+
 // Describe implements the prometheus.Collector interface.
 func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeDurationDesc
 	ch <- scrapeSuccessDesc
+	ch <- connectionAgeDesc
+	ch <- connectionReconnectsDesc
+	ch <- deviceBackoffDesc
+	ch <- deviceReachableDesc
+	ch <- queueWaitDesc
+	ch <- scrapeErrorsDesc
+	ch <- routerOSInfoDesc
+	ch <- routerOSCapabilityDesc
+	ch <- lastSuccessfulPollDesc
+	ch <- pollStalenessDesc
 
 	for _, co := range c.collectors {
 		co.describe(ch)
 	}
+
+	for _, collectors := range c.featureCollectors {
+		for _, co := range collectors {
+			co.describe(ch)
+		}
+	}
 }
 
 // Collect implements the prometheus.Collector interface.
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	if c.polls != nil {
+		c.polls.collect(ch)
+		return
+	}
+
+	var deadline time.Time
+	if c.scrapeTimeout > 0 {
+		deadline = time.Now().Add(c.scrapeTimeout)
+	}
+
 	wg := sync.WaitGroup{}
 	wg.Add(len(c.devices))
 
 	for _, dev := range c.devices {
 		go func(d config.Device) {
-			c.collectForDevice(d, ch)
+			c.collectForDevice(d, deadline, metricChannel(ch))
 			wg.Done()
 		}(dev)
 	}
@@ -214,107 +504,408 @@ func (c *collector) Collect(ch chan<- prometheus.Metric) {
 	wg.Wait()
 }
 
-func (c *collector) collectForDevice(d config.Device, ch chan<- prometheus.Metric) {
-	begin := time.Now()
+// scrapeResults receives the results of collecting a device, either sending
+// them to Prometheus right away or keeping them until the next scrape when
+// polling in the background
+type scrapeResults interface {
+	collectorDone(d *config.Device, co routerOSCollector, metrics []prometheus.Metric, duration time.Duration, err error)
+	deviceDone(d *config.Device, metrics []prometheus.Metric)
+}
+
+// metricChannel sends the results of a device to Prometheus
+type metricChannel chan<- prometheus.Metric
+
+func (ch metricChannel) collectorDone(d *config.Device, co routerOSCollector, metrics []prometheus.Metric, duration time.Duration, err error) {
+	for _, m := range metrics {
+		ch <- m
+	}
+
+	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), d.Name, co.name())
+	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, successValue(err), d.Name, co.name())
+}
+
+func (ch metricChannel) deviceDone(d *config.Device, metrics []prometheus.Metric) {
+	for _, m := range metrics {
+		ch <- m
+	}
+}
 
-	err := c.connectAndCollect(&d, ch)
+// deviceTLS returns the TLS settings of a device. Settings of the device
+// replace the global ones, the tls and insecure flags override both.
+func (c *collector) deviceTLS(t *config.TLSConfig) *config.TLSConfig {
+	if t == nil {
+		t = c.tlsConfig
+	}
+
+	if !c.tlsFlag && !c.tlsInsecure {
+		return t
+	}
 
-	duration := time.Since(begin)
-	var success float64
+	merged := config.TLSConfig{}
+	if t != nil {
+		merged = *t
+	}
+	if c.tlsFlag {
+		merged.Enabled = true
+	}
+	if c.tlsInsecure {
+		merged.InsecureSkipVerify = true
+	}
+
+	return &merged
+}
+
+func successValue(err error) float64 {
 	if err != nil {
-		log.Errorf("ERROR: %s collector failed after %fs: %s", d.Name, duration.Seconds(), err)
-		success = 0
-	} else {
-		log.Debugf("OK: %s collector succeeded after %fs.", d.Name, duration.Seconds())
-		success = 1
+		return 0
 	}
 
-	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), d.Name)
-	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, d.Name)
+	return 1
 }
 
-func (c *collector) connectAndCollect(d *config.Device, ch chan<- prometheus.Metric) error {
-	cl, err := c.connect(d)
+func (c *collector) collectForDevice(d config.Device, deadline time.Time, results scrapeResults) {
+	d.TLS = c.deviceTLS(d.TLS)
+
+	wait, release := c.waitToStart(deadline)
+	defer release()
+
+	metrics := []prometheus.Metric{
+		prometheus.MustNewConstMetric(queueWaitDesc, prometheus.GaugeValue, wait.Seconds(), d.Name),
+	}
+
+	if c.replayDir != "" {
+		v := c.replayForDevice(&d, deadline, results)
+		metrics = append(metrics, versionMetrics(d.Name, v)...)
+		results.deviceDone(&d, append(metrics, c.scrapeErrors.metrics(d.Name)...))
+		return
+	}
+
+	conn := c.connections.acquire(&d)
+	c.connectAndCollect(conn, &d, deadline, results)
+	age := conn.age()
+	reconnects := conn.reconnects
+	version := conn.version
+	backoff := conn.backoff()
+	reachable := 0.0
+	if conn.reachable() {
+		reachable = 1
+	}
+	c.connections.release(conn)
+
+	metrics = append(metrics,
+		prometheus.MustNewConstMetric(connectionReconnectsDesc, prometheus.CounterValue, float64(reconnects), d.Name),
+		prometheus.MustNewConstMetric(deviceBackoffDesc, prometheus.GaugeValue, backoff.Seconds(), d.Name),
+		prometheus.MustNewConstMetric(deviceReachableDesc, prometheus.GaugeValue, reachable, d.Name),
+	)
+	if age > 0 {
+		metrics = append(metrics, prometheus.MustNewConstMetric(connectionAgeDesc, prometheus.GaugeValue, age.Seconds(), d.Name))
+	}
+	metrics = append(metrics, versionMetrics(d.Name, version)...)
+	metrics = append(metrics, c.scrapeErrors.metrics(d.Name)...)
+	results.deviceDone(&d, metrics)
+}
+
+// connectAndCollect runs all collectors of the device on its connection
+func (c *collector) connectAndCollect(conn *connection, d *config.Device, deadline time.Time, results scrapeResults) {
+	conn.startScrape()
+
+	c.runCollectors(d, deadline, results, func(co routerOSCollector, ctx *collectorContext) error {
+		return c.collectWithCollector(co, conn, d, deadline, ctx)
+	})
+}
+
+// replayForDevice runs all collectors of the device on its recorded replies
+// and returns the recorded RouterOS version
+func (c *collector) replayForDevice(d *config.Device, deadline time.Time, results scrapeResults) routerOSVersion {
+	var version routerOSVersion
+
+	cl, err := loadReplayClient(c.replayDir, d.Name)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"device": d.Name,
 			"error":  err,
-		}).Error("error dialing device")
-		return err
+		}).Error("error loading recording")
+	} else {
+		version, _ = detectVersion(cl, d.Name)
 	}
-	defer cl.Close()
 
-	for _, co := range c.collectors {
-		ctx := &collectorContext{ch, d, cl}
-		err = co.collect(ctx)
+	c.runCollectors(d, deadline, results, func(co routerOSCollector, ctx *collectorContext) error {
 		if err != nil {
 			return err
 		}
+
+		if !collectorSupports(co, version) {
+			return errUnsupported
+		}
+
+		ctx.client = cl
+		ctx.version = version
+		return co.collect(ctx)
+	})
+
+	return version
+}
+
+// runCollectors runs all collectors of the device and reports their results.
+// A failing collector does not prevent the remaining collectors from running.
+// Errors are counted by reason.
+func (c *collector) runCollectors(d *config.Device, deadline time.Time, results scrapeResults, collect func(co routerOSCollector, ctx *collectorContext) error) {
+	// connectErr fails the remaining collectors without connecting again, so
+	// a connection failure is only logged and counted once per scrape
+	var connectErr error
+
+	for _, co := range c.collectorsForDevice(d) {
+		begin := time.Now()
+
+		var metrics []prometheus.Metric
+		var err error
+		var parseErrors int
+		if connectErr != nil {
+			results.collectorDone(d, co, nil, 0, connectErr)
+			continue
+		}
+
+		if !deadline.IsZero() && !begin.Before(deadline) {
+			err = errScrapeTimeout
+		} else {
+			metrics, err = bufferMetrics(func(ch chan<- prometheus.Metric) error {
+				ctx := &collectorContext{ch: ch, device: d}
+				err := collect(co, ctx)
+				parseErrors = ctx.parseErrors
+				return err
+			})
+		}
+
+		if err == errUnsupported {
+			log.Debugf("SKIP: %s %s collector is not supported by the RouterOS version", d.Name, co.name())
+			continue
+		}
+
+		if parseErrors > 0 {
+			c.scrapeErrors.add(d.Name, co.name(), reasonParse, parseErrors)
+		}
+
+		duration := time.Since(begin)
+		if _, ok := err.(*dialError); ok {
+			connectErr = err
+			c.reportConnectError(d, err)
+		} else if err != nil {
+			reason := errorReason(err)
+			c.scrapeErrors.add(d.Name, co.name(), reason, 1)
+			if isBackoff(err) {
+				log.Debugf("ERROR: %s %s collector skipped, device in backoff: %s", d.Name, co.name(), err)
+			} else {
+				log.Errorf("ERROR: %s %s collector failed after %fs (%s): %s", d.Name, co.name(), duration.Seconds(), reason, err)
+			}
+		} else {
+			log.Debugf("OK: %s %s collector succeeded after %fs.", d.Name, co.name(), duration.Seconds())
+		}
+
+		results.collectorDone(d, co, metrics, duration, err)
+	}
+}
+
+// reportConnectError counts and logs a failed connection to the device. It is
+// counted for the connect collector, not for the collectors failing due to it.
+func (c *collector) reportConnectError(d *config.Device, err error) {
+	reason := errorReason(err)
+	c.scrapeErrors.add(d.Name, connectCollectorName, reason, 1)
+	if isBackoff(err) {
+		log.Debugf("ERROR: %s collectors skipped, device in backoff: %s", d.Name, err)
+	} else {
+		log.Errorf("ERROR: %s could not connect (%s): %s", d.Name, reason, err)
+	}
+}
+
+// bufferMetrics returns the metrics sent by collect
+func bufferMetrics(collect func(ch chan<- prometheus.Metric) error) ([]prometheus.Metric, error) {
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+
+	var metrics []prometheus.Metric
+	go func() {
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		close(done)
+	}()
+
+	err := collect(ch)
+	close(ch)
+	<-done
+
+	return metrics, err
+}
+
+// collectWithCollector runs a single collector, reconnecting first if a
+// previous collector closed the connection
+func (c *collector) collectWithCollector(co routerOSCollector, conn *connection, d *config.Device, deadline time.Time, ctx *collectorContext) error {
+	client, err := c.connectAndDetectVersion(conn, d, deadline)
+	if err != nil {
+		return err
+	}
+
+	if !collectorSupports(co, conn.version) {
+		return errUnsupported
+	}
+
+	ctx.client = client
+	ctx.version = conn.version
+	err = co.collect(ctx)
+	if err != nil {
+		conn.invalidate(err)
+		return err
 	}
 
 	return nil
 }
 
-func (c *collector) connect(d *config.Device) (*routeros.Client, error) {
+// connectAndDetectVersion returns the client of the connection and detects
+// the RouterOS version once per connection. If the device refuses the
+// command, e.g. due to missing permissions, the version stays unknown and the
+// detection is not tried again.
+func (c *collector) connectAndDetectVersion(conn *connection, d *config.Device, deadline time.Time) (commandRunner, error) {
+	for {
+		cl, err := conn.connect(d, deadline, c.connect)
+		if err != nil {
+			// logged by reportConnectError
+			return nil, &dialError{err}
+		}
+
+		var client commandRunner = cl
+		if c.recordDir != "" {
+			client = newRecordingClient(cl, c.recordDir, d.Name)
+		}
+
+		if conn.versionDetected || conn.versionRefused {
+			return client, nil
+		}
+
+		v, err := detectVersion(client, d.Name)
+		if err == nil {
+			conn.version = v
+			conn.versionDetected = true
+			return client, nil
+		}
+
+		conn.invalidate(err)
+		if _, ok := err.(*routeros.DeviceError); !ok {
+			return nil, err
+		}
+
+		log.WithFields(log.Fields{
+			"device": d.Name,
+			"error":  err,
+		}).Warn("could not detect RouterOS version")
+		conn.versionRefused = true
+	}
+}
+
+// connect dials the device and logs in, trying the fallback credentials of the
+// device in order if the login is rejected
+func (c *collector) connect(d *config.Device, deadline time.Time) (*apiClient, error) {
+	var err error
+
+	for i, cred := range d.Logins() {
+		var client *apiClient
+		client, err = c.dial(d, deadline)
+		if err != nil {
+			return nil, err
+		}
+
+		log.WithField("device", d.Name).Debug("trying to login")
+		err = login(client, cred.User, cred.Password)
+		if err == nil {
+			if i > 0 {
+				log.WithFields(log.Fields{
+					"device": d.Name,
+					"user":   cred.User,
+				}).Warn("logged in with fallback credentials")
+			}
+			log.WithField("device", d.Name).Debug("done wth login")
+			return client, nil
+		}
+		client.Close()
+
+		if _, ok := err.(*routeros.DeviceError); !ok {
+			return nil, err
+		}
+		err = &authError{err}
+
+		log.WithFields(log.Fields{
+			"device": d.Name,
+			"user":   cred.User,
+			"error":  err,
+		}).Debug("login failed")
+	}
+
+	return nil, err
+}
+
+func (c *collector) dial(d *config.Device, deadline time.Time) (*apiClient, error) {
 	var conn net.Conn
 	var err error
 
+	timeout := timeoutUntil(c.timeout, deadline)
+	if timeout <= 0 {
+		return nil, errScrapeTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+
 	log.WithField("device", d.Name).Debug("trying to Dial")
-	if !c.enableTLS {
-		conn, err = net.Dial("tcp", d.Address+apiPort)
+	if d.TLS == nil || !d.TLS.Enabled {
+		conn, err = dialer.Dial("tcp", dialAddress(d, apiPort))
 		if err != nil {
 			return nil, err
 		}
-		//		return routeros.DialTimeout(d.Address+apiPort, d.User, d.Password, c.timeout)
 	} else {
-		tlsCfg := &tls.Config{
-			InsecureSkipVerify: c.insecureTLS,
+		address := dialAddress(d, apiPortTLS)
+		tlsCfg, err := newTLSConfig(d.TLS, address)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err = dialer.Dial("tcp", address)
+		if err != nil {
+			return nil, err
 		}
-		conn, err = tls.Dial("tcp", d.Address+apiPortTLS, tlsCfg)
+
+		conn, err = handshakeTLS(conn, tlsCfg, timeoutUntil(c.timeout, deadline))
 		if err != nil {
 			return nil, err
 		}
 	}
 	log.WithField("device", d.Name).Debug("done dialing")
 
-	client, err := routeros.NewClient(conn)
-	if err != nil {
-		return nil, err
-	}
+	client := newAPIClient(conn, c.timeout)
+	client.deadline = deadline
 	log.WithField("device", d.Name).Debug("got client")
 
-	log.WithField("device", d.Name).Debug("trying to login")
-	r, err := client.Run("/login", "=name="+d.User, "=password="+d.Password)
+	return client, nil
+}
+
+func login(client *apiClient, user, password string) error {
+	r, err := client.Run("/login", "=name="+user, "=password="+password)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	ret, ok := r.Done.Map["ret"]
 	if !ok {
 		// Login method post-6.43 one stage, cleartext and no challenge
 		if r.Done != nil {
-			return client, nil
+			return nil
 		}
-		return nil, errors.New("RouterOS: /login: no ret (challenge) received")
+		return errors.New("RouterOS: /login: no ret (challenge) received")
 	}
 
 	// Login method pre-6.43 two stages, challenge
 	b, err := hex.DecodeString(ret)
 	if err != nil {
-		return nil, fmt.Errorf("RouterOS: /login: invalid ret (challenge) hex string received: %s", err)
-	}
-
-	r, err = client.Run("/login", "=name="+d.User, "=response="+challengeResponse(b, d.Password))
-	if err != nil {
-		return nil, err
+		return fmt.Errorf("RouterOS: /login: invalid ret (challenge) hex string received: %s", err)
 	}
-	log.WithField("device", d.Name).Debug("done wth login")
-
-	return client, nil
 
-	//tlsCfg := &tls.Config{
-	//	InsecureSkipVerify: c.insecureTLS,
-	//}
-	//	return routeros.DialTLSTimeout(d.Address+apiPortTLS, d.User, d.Password, tlsCfg, c.timeout)
+	_, err = client.Run("/login", "=name="+user, "=response="+challengeResponse(b, password))
+	return err
 }
 
 func challengeResponse(cha []byte, password string) string {
@@ -2,12 +2,22 @@ package collector
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"gopkg.in/routeros.v2"
 	"mikrotik-exporter/config"
 )
 
 type collectorContext struct {
 	ch     chan<- prometheus.Metric
 	device *config.Device
-	client *routeros.Client
+	client commandRunner
+
+	// version is the RouterOS version of the device, if known
+	version routerOSVersion
+
+	parseErrors int
+}
+
+// parseError counts a value which could not be parsed. The value is skipped,
+// so it does not fail the collector.
+func (ctx *collectorContext) parseError() {
+	ctx.parseErrors++
 }
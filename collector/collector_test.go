@@ -0,0 +1,304 @@
+package collector
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"mikrotik-exporter/collector/routerostest"
+	"mikrotik-exporter/config"
+)
+
+const (
+	testUser     = "prometheus"
+	testPassword = "secret"
+)
+
+// newTestServer starts a fake device answering the commands of the collectors
+// which are always enabled
+func newTestServer(t *testing.T) *routerostest.Server {
+	s, err := routerostest.NewServer(testUser, testPassword)
+	if err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+
+	s.Handle("/system/identity/print", routerostest.Reply{
+		Re: []map[string]string{{"name": "MikroTik"}},
+	})
+	s.Handle("/system/resource/print", routerostest.Reply{
+		Re: []map[string]string{{
+			"free-memory":     "1024",
+			"total-memory":    "4096",
+			"cpu-load":        "7",
+			"free-hdd-space":  "2048",
+			"total-hdd-space": "8192",
+			"uptime":          "1d2h",
+			"board-name":      "RB4011",
+			"version":         "6.45.9 (long-term)",
+		}},
+	})
+	s.Handle("/system/clock/print", routerostest.Reply{
+		Re: []map[string]string{{"date": "oct/18/2026", "time": "12:00:00"}},
+	})
+	s.Handle("/interface/print ?disabled=false", routerostest.Reply{
+		Re: []map[string]string{{
+			"name":              "ether1",
+			"comment":           "uplink",
+			"mac-address":       "00:11:22:33:44:55",
+			"type":              "ether",
+			"last-link-up-time": "oct/18/2026 11:00:00",
+			"running":           "true",
+			"actual-mtu":        "1500",
+			"rx-byte":           "1000",
+			"tx-byte":           "2000",
+		}},
+	})
+
+	return s
+}
+
+func testDevice(s *routerostest.Server, password string) config.Device {
+	return config.Device{
+		Name:     "router",
+		Address:  s.Addr(),
+		User:     testUser,
+		Password: password,
+	}
+}
+
+// collectMetrics runs a scrape and returns all values by metric name and
+// labels, leaving out the name and address labels of the device
+func collectMetrics(t *testing.T, c prometheus.Collector) map[string]float64 {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("could not gather metrics: %v", err)
+	}
+
+	metrics := make(map[string]float64)
+	for _, mf := range families {
+		for _, m := range mf.Metric {
+			labels := []string{}
+			for _, l := range m.Label {
+				if l.GetName() == "name" || l.GetName() == "address" || l.GetName() == "device" {
+					continue
+				}
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			sort.Strings(labels)
+
+			var v float64
+			switch {
+			case m.Gauge != nil:
+				v = m.Gauge.GetValue()
+			case m.Counter != nil:
+				v = m.Counter.GetValue()
+			}
+			metrics[mf.GetName()+"{"+strings.Join(labels, ",")+"}"] = v
+		}
+	}
+
+	return metrics
+}
+
+// newTestCollector creates a collector for the given devices with its own
+// connections and error counters, so tests do not share them
+func newTestCollector(t *testing.T, cfg *config.Config, opts ...Option) prometheus.Collector {
+	withConnections := func(c *collector) {
+		c.connections = newConnectionManager()
+		c.scrapeErrors = newScrapeErrors()
+	}
+
+	c, err := NewCollector(cfg, append([]Option{withConnections}, opts...)...)
+	if err != nil {
+		t.Fatalf("could not create collector: %v", err)
+	}
+
+	return c
+}
+
+func TestCollectShouldLogin(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		s := newTestServer(t)
+		s.LegacyLogin = legacy
+
+		cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+		metrics := collectMetrics(t, newTestCollector(t, cfg))
+		s.Close()
+
+		assert.Equal(t, 1, s.Logins(), "legacy login: %v", legacy)
+		assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=interface}"], "legacy login: %v", legacy)
+		assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=resource}"], "legacy login: %v", legacy)
+		assert.Equal(t, 7.0, metrics["mikrotik_system_cpu_load{boardname=RB4011,version=6.45.9 (long-term)}"], "legacy login: %v", legacy)
+		assert.Equal(t, 93600.0, metrics["mikrotik_system_uptime{boardname=RB4011,version=6.45.9 (long-term)}"], "legacy login: %v", legacy)
+		assert.Equal(t, 3600.0, metrics["mikrotik_interface_last_link_up_time{comment=uplink,interface=ether1,mac_address=00:11:22:33:44:55,type=ether}"], "legacy login: %v", legacy)
+		assert.Equal(t, 1.0, metrics["mikrotik_interface_running{comment=uplink,interface=ether1,mac_address=00:11:22:33:44:55,type=ether}"], "legacy login: %v", legacy)
+		assert.Equal(t, 2000.0, metrics["mikrotik_interface_tx_byte{comment=uplink,interface=ether1,mac_address=00:11:22:33:44:55,type=ether}"], "legacy login: %v", legacy)
+	}
+}
+
+func TestCollectShouldFailWithWrongPassword(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, "wrong")}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg))
+
+	assert.Equal(t, 0, s.Logins())
+	assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=interface}"])
+	assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+	assert.Empty(t, s.Commands())
+}
+
+func TestCollectShouldLoginWithFallbackCredentials(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	d := testDevice(s, "new")
+	d.Fallback = []config.Credential{{User: testUser, Password: testPassword}}
+
+	cfg := &config.Config{Devices: []config.Device{d}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg))
+
+	assert.Equal(t, 1, s.Logins())
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+}
+
+func TestCollectShouldContinueAfterFailingCollector(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/routing/bgp/peer/print", routerostest.Reply{Trap: "no such command prefix"})
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	c := newTestCollector(t, cfg, WithBGP(), WithPool())
+
+	s.Handle("/ip/pool/print", routerostest.Reply{
+		Re: []map[string]string{{"name": "dhcp"}},
+	})
+	s.Handle("/ip/pool/used/print ?pool=dhcp =count-only=", routerostest.Reply{
+		Done: map[string]string{"ret": "12"},
+	})
+
+	for i := 0; i < 2; i++ {
+		metrics := collectMetrics(t, c)
+
+		assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=interface}"])
+		assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+		assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=bgp}"])
+		assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=pool}"])
+		assert.Equal(t, 12.0, metrics["mikrotik_ip_pool_ipv4_used_count{pool=dhcp}"])
+		assert.Equal(t, 0.0, metrics["mikrotik_connection_reconnects_total{}"], "a failing command keeps the connection")
+	}
+
+	assert.Equal(t, 1, s.Logins())
+}
+
+func TestCollectShouldReuseConnection(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	c := newTestCollector(t, cfg)
+
+	collectMetrics(t, c)
+	metrics := collectMetrics(t, c)
+	assert.Equal(t, 1, s.Logins())
+	assert.Equal(t, 0.0, metrics["mikrotik_connection_reconnects_total{}"])
+
+	s.CloseConnections()
+
+	metrics = collectMetrics(t, c)
+	assert.Equal(t, 2, s.Logins())
+	assert.Equal(t, 1.0, metrics["mikrotik_connection_reconnects_total{}"])
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+}
+
+func TestCollectShouldCheckConnectionOncePerScrape(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/routing/bgp/peer/print", routerostest.Reply{Trap: "no such command prefix"})
+	s.Handle("/ip/pool/print", routerostest.Reply{})
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	c := newTestCollector(t, cfg, WithBGP(), WithPool())
+
+	healthChecks := func() int {
+		n := 0
+		for _, cmd := range s.Commands() {
+			if cmd[0] == "/system/identity/print" {
+				n++
+			}
+		}
+		return n
+	}
+
+	collectMetrics(t, c)
+	assert.Equal(t, 0, healthChecks(), "a new connection is not checked")
+
+	collectMetrics(t, c)
+	collectMetrics(t, c)
+	assert.Equal(t, 2, healthChecks())
+	assert.Equal(t, 1, s.Logins())
+}
+
+func TestTLSFlagsShouldOverrideConfig(t *testing.T) {
+	global := &config.TLSConfig{CAFile: "ca.pem", MinVersion: "TLS12"}
+	cfg := &config.Config{TLS: global}
+
+	c := newTestCollector(t, cfg, WithTLS(true)).(*collector)
+	tlsCfg := c.deviceTLS(nil)
+	assert.True(t, tlsCfg.Enabled)
+	assert.True(t, tlsCfg.InsecureSkipVerify)
+	assert.Equal(t, "ca.pem", tlsCfg.CAFile)
+	assert.Equal(t, "TLS12", tlsCfg.MinVersion)
+	assert.False(t, global.InsecureSkipVerify, "config is not modified")
+
+	device := &config.TLSConfig{Enabled: true, ServerName: "router.example.com"}
+	c = newTestCollector(t, cfg, WithInsecureTLS()).(*collector)
+	tlsCfg = c.deviceTLS(device)
+	assert.True(t, tlsCfg.InsecureSkipVerify)
+	assert.Equal(t, "router.example.com", tlsCfg.ServerName)
+	assert.Empty(t, tlsCfg.CAFile, "device settings replace global ones")
+
+	c = newTestCollector(t, cfg).(*collector)
+	assert.Equal(t, global, c.deviceTLS(nil))
+	assert.Equal(t, device, c.deviceTLS(device))
+}
+
+func TestBuiltinMetricsShouldUseBuiltinPrefixes(t *testing.T) {
+	f := config.Features{}
+	fv := reflect.ValueOf(&f).Elem()
+	for i := 0; i < fv.NumField(); i++ {
+		if fv.Field(i).Kind() == reflect.Bool {
+			fv.Field(i).SetBool(true)
+		}
+	}
+
+	cfg := &config.Config{Features: f, Devices: []config.Device{{Name: "router"}}}
+	c := newTestCollector(t, cfg)
+
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+
+	fqName := regexp.MustCompile(`fqName: "([^"]+)"`)
+	for d := range ch {
+		name := strings.TrimPrefix(fqName.FindStringSubmatch(d.String())[1], namespace+"_")
+
+		found := false
+		for _, p := range config.BuiltinMetricPrefixes {
+			found = found || strings.HasPrefix(name, p+"_")
+		}
+		assert.True(t, found, "%s does not use a prefix of config.BuiltinMetricPrefixes", name)
+	}
+}
@@ -0,0 +1,303 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2"
+	"mikrotik-exporter/config"
+)
+
+const (
+	connectionIdleTimeout = 5 * time.Minute
+	reconnectBackoffMin   = time.Second
+	reconnectBackoffMax   = time.Minute
+
+	// breakerFailureThreshold is the number of failed connects in a row after
+	// which a device is considered unreachable and not dialed again until its
+	// backoff expired
+	breakerFailureThreshold = 3
+)
+
+// defaultConnectionManager is shared by all collectors, so connections survive
+// collectors created per request or on config changes
+var defaultConnectionManager = newConnectionManager()
+
+type dialFunc func(d *config.Device, deadline time.Time) (*apiClient, error)
+
+// connectionManager keeps one authenticated API connection per device
+type connectionManager struct {
+	mu          sync.Mutex
+	connections map[string]*connection
+}
+
+// connection holds the API client of a device. The client must only be used
+// while holding mu, as the API does not allow concurrent commands.
+type connection struct {
+	mu         sync.Mutex
+	users      int
+	lastUsed   time.Time
+	device     config.Device
+	client     *apiClient
+	created    time.Time
+	connected  bool
+	checked    bool
+	reconnects int
+	failures   int
+	lastError  error
+	retryAfter time.Time
+
+	// loginRejected is set if the device answered the last attempt to connect,
+	// but rejected the credentials
+	loginRejected bool
+
+	// version is detected once per connection, before the first collector
+	version         routerOSVersion
+	versionDetected bool
+	versionRefused  bool
+}
+
+type backoffError struct {
+	retryAfter time.Time
+	err        error
+}
+
+func (e *backoffError) Error() string {
+	return fmt.Sprintf("not reconnecting before %s, last error: %v", e.retryAfter.Format(time.RFC3339), e.err)
+}
+
+func isBackoff(err error) bool {
+	if e, ok := err.(*dialError); ok {
+		err = e.err
+	}
+
+	_, ok := err.(*backoffError)
+	return ok
+}
+
+// dialError marks errors which occurred while connecting to a device
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string {
+	return e.err.Error()
+}
+
+func newConnectionManager() *connectionManager {
+	return &connectionManager{
+		connections: make(map[string]*connection),
+	}
+}
+
+// acquire returns the locked connection for a device. It has to be given back
+// by calling release.
+func (m *connectionManager) acquire(d *config.Device) *connection {
+	m.mu.Lock()
+	m.closeIdle()
+	conn, found := m.connections[d.Name]
+	if !found {
+		conn = &connection{}
+		m.connections[d.Name] = conn
+	}
+	conn.users++
+	m.mu.Unlock()
+
+	conn.mu.Lock()
+	return conn
+}
+
+func (m *connectionManager) release(conn *connection) {
+	conn.mu.Unlock()
+
+	m.mu.Lock()
+	conn.users--
+	conn.lastUsed = time.Now()
+	m.mu.Unlock()
+}
+
+// closeIdle drops connections of devices which have not been collected for a
+// while, e.g. probed targets or devices removed from the config
+func (m *connectionManager) closeIdle() {
+	for name, conn := range m.connections {
+		if conn.users > 0 || time.Since(conn.lastUsed) < connectionIdleTimeout {
+			continue
+		}
+
+		log.WithField("device", name).Debug("closing idle connection")
+		conn.close()
+		delete(m.connections, name)
+	}
+}
+
+// connect returns a healthy client for the device, reusing the existing client
+// if possible. Commands of the client will fail once the deadline is reached.
+func (conn *connection) connect(d *config.Device, deadline time.Time, dial dialFunc) (*apiClient, error) {
+	if conn.client != nil && !sameTarget(&conn.device, d) {
+		log.WithField("device", d.Name).Info("device config changed, reconnecting")
+		conn.close()
+		conn.connected = false
+		conn.versionRefused = false
+	}
+
+	if conn.client != nil {
+		conn.client.deadline = deadline
+		if conn.checked {
+			return conn.client, nil
+		}
+
+		err := conn.checkHealth()
+		if err == nil {
+			conn.checked = true
+			return conn.client, nil
+		}
+
+		log.WithFields(log.Fields{
+			"device": d.Name,
+			"error":  err,
+		}).Info("connection is broken, reconnecting")
+		conn.close()
+	}
+
+	if !conn.retryAfter.IsZero() {
+		if time.Now().Before(conn.retryAfter) {
+			return nil, &backoffError{retryAfter: conn.retryAfter, err: conn.lastError}
+		}
+
+		// half-open: a single attempt decides whether the device is
+		// reachable again or stays in backoff for longer
+		log.WithFields(log.Fields{
+			"device":   d.Name,
+			"failures": conn.failures,
+		}).Info("backoff expired, trying to reconnect")
+	}
+
+	client, err := dial(d, deadline)
+	if err == errScrapeTimeout {
+		return nil, err
+	}
+	if _, ok := err.(*authError); ok {
+		// the device is reachable, backing off would not fix the credentials
+		conn.failures = 0
+		conn.retryAfter = time.Time{}
+		conn.lastError = err
+		conn.loginRejected = true
+		return nil, err
+	}
+	if err != nil {
+		conn.loginRejected = false
+		conn.failures++
+		conn.lastError = err
+		if conn.failures >= breakerFailureThreshold {
+			backoff := reconnectBackoff(conn.failures - breakerFailureThreshold + 1)
+			conn.retryAfter = time.Now().Add(backoff)
+			log.WithFields(log.Fields{
+				"device":   d.Name,
+				"failures": conn.failures,
+				"backoff":  backoff,
+			}).Warn("device unreachable, backing off")
+		}
+		return nil, err
+	}
+
+	if conn.failures >= breakerFailureThreshold {
+		log.WithField("device", d.Name).Info("device reachable again")
+	}
+
+	if conn.connected {
+		conn.reconnects++
+	}
+	conn.client = client
+	conn.device = *d
+	conn.created = time.Now()
+	conn.connected = true
+	conn.checked = true
+	conn.versionDetected = false
+	conn.failures = 0
+	conn.lastError = nil
+	conn.retryAfter = time.Time{}
+	conn.loginRejected = false
+
+	return client, nil
+}
+
+// backoff returns the time until the device is dialed again
+func (conn *connection) backoff() time.Duration {
+	if d := time.Until(conn.retryAfter); d > 0 {
+		return d
+	}
+
+	return 0
+}
+
+// reachable returns whether the device answered the last attempt to connect,
+// even if it rejected the login
+func (conn *connection) reachable() bool {
+	return (conn.connected || conn.loginRejected) && conn.failures == 0
+}
+
+// startScrape makes the next connect check the health of the client once,
+// later collectors of the scrape reuse it until it is invalidated
+func (conn *connection) startScrape() {
+	conn.checked = false
+}
+
+func (conn *connection) checkHealth() error {
+	_, err := conn.client.Run("/system/identity/print")
+	return err
+}
+
+// invalidate closes the client after an error which may have left the
+// connection in an unknown state. Errors reported by the device leave it
+// usable, unless the device closed the connection with a !fatal.
+func (conn *connection) invalidate(err error) {
+	if _, ok := err.(*routeros.DeviceError); (ok && !isFatal(err)) || err == errScrapeTimeout {
+		return
+	}
+
+	conn.close()
+}
+
+func (conn *connection) close() {
+	if conn.client == nil {
+		return
+	}
+
+	conn.client.Close()
+	conn.client = nil
+}
+
+func (conn *connection) age() time.Duration {
+	if conn.client == nil {
+		return 0
+	}
+
+	return time.Since(conn.created)
+}
+
+func sameTarget(a, b *config.Device) bool {
+	return a.Address == b.Address && a.Port == b.Port && a.User == b.User && a.Password == b.Password && sameTLS(a.TLS, b.TLS)
+}
+
+func sameTLS(a, b *config.TLSConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func reconnectBackoff(failures int) time.Duration {
+	d := reconnectBackoffMin
+	for i := 1; i < failures && d < reconnectBackoffMax; i++ {
+		d *= 2
+	}
+
+	if d > reconnectBackoffMax {
+		return reconnectBackoffMax
+	}
+
+	return d
+}
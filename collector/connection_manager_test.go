@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"mikrotik-exporter/config"
+)
+
+func failingDial(calls *int, err error) dialFunc {
+	return func(d *config.Device, deadline time.Time) (*apiClient, error) {
+		*calls++
+		return nil, err
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, reconnectBackoff(test.failures), "failures: %d", test.failures)
+	}
+}
+
+func TestConnectShouldBackOffAfterRepeatedFailures(t *testing.T) {
+	d := &config.Device{Name: "router"}
+	conn := &connection{}
+
+	var calls int
+	dial := failingDial(&calls, errors.New("connection refused"))
+
+	for i := 0; i < breakerFailureThreshold; i++ {
+		assert.Equal(t, 0*time.Second, conn.backoff())
+		_, err := conn.connect(d, time.Time{}, dial)
+		assert.False(t, isBackoff(err))
+	}
+
+	_, err := conn.connect(d, time.Time{}, dial)
+	assert.True(t, isBackoff(err))
+	assert.True(t, isBackoff(&dialError{err}))
+	assert.Equal(t, breakerFailureThreshold, calls)
+	assert.True(t, conn.backoff() > 0)
+	assert.False(t, conn.reachable())
+}
+
+func TestConnectShouldRetryOnceAfterBackoff(t *testing.T) {
+	d := &config.Device{Name: "router"}
+	conn := &connection{
+		failures:   breakerFailureThreshold,
+		retryAfter: time.Now().Add(-time.Millisecond),
+	}
+
+	var calls int
+	_, err := conn.connect(d, time.Time{}, failingDial(&calls, errors.New("connection refused")))
+	assert.False(t, isBackoff(err))
+	assert.Equal(t, 1, calls)
+	assert.True(t, conn.backoff() > time.Second, "backoff grows after a failed retry")
+
+	conn.retryAfter = time.Now().Add(-time.Millisecond)
+	client := &apiClient{}
+	cl, err := conn.connect(d, time.Time{}, func(d *config.Device, deadline time.Time) (*apiClient, error) {
+		return client, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, client, cl)
+	assert.Equal(t, 0*time.Second, conn.backoff())
+	assert.True(t, conn.reachable())
+}
+
+func TestConnectShouldNotCountScrapeTimeouts(t *testing.T) {
+	d := &config.Device{Name: "router"}
+	conn := &connection{}
+
+	var calls int
+	for i := 0; i < breakerFailureThreshold+1; i++ {
+		_, err := conn.connect(d, time.Time{}, failingDial(&calls, errScrapeTimeout))
+		assert.Equal(t, errScrapeTimeout, err)
+	}
+
+	assert.Equal(t, 0, conn.failures)
+	assert.Equal(t, 0*time.Second, conn.backoff())
+}
+
+func TestCollectShouldReportUnreachableDevice(t *testing.T) {
+	s := newTestServer(t)
+	d := testDevice(s, testPassword)
+
+	cfg := &config.Config{Devices: []config.Device{d}}
+	c := newTestCollector(t, cfg)
+
+	metrics := collectMetrics(t, c)
+	assert.Equal(t, 1.0, metrics["mikrotik_device_reachable{}"])
+	assert.Equal(t, 0.0, metrics["mikrotik_device_backoff_seconds{}"])
+
+	s.Close()
+	for i := 0; i < breakerFailureThreshold; i++ {
+		metrics = collectMetrics(t, c)
+	}
+
+	assert.Equal(t, 0.0, metrics["mikrotik_device_reachable{}"])
+	assert.True(t, metrics["mikrotik_device_backoff_seconds{}"] > 0)
+}
+
+func TestConnectShouldNotBackOffAfterRejectedLogins(t *testing.T) {
+	d := &config.Device{Name: "router"}
+	conn := &connection{}
+
+	var calls int
+	dial := failingDial(&calls, &authError{errors.New("invalid user name or password (6)")})
+
+	for i := 0; i < breakerFailureThreshold+1; i++ {
+		_, err := conn.connect(d, time.Time{}, dial)
+		assert.False(t, isBackoff(err))
+	}
+
+	assert.Equal(t, breakerFailureThreshold+1, calls)
+	assert.Equal(t, 0*time.Second, conn.backoff())
+	assert.True(t, conn.reachable())
+
+	_, err := conn.connect(d, time.Time{}, failingDial(&calls, errors.New("connection refused")))
+	assert.Error(t, err)
+	assert.False(t, conn.reachable())
+}
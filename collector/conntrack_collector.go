@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+type conntrackCollector struct {
+	topN         int
+	protocols    []string
+	tcpStates    []string
+	props        []string
+	descriptions map[string]*prometheus.Desc
+}
+
+// newConntrackCollector creates a collector for the connection tracking
+// table. With topN > 0, the connections of the topN source addresses with
+// the most connections are counted as well.
+func newConntrackCollector(topN int) routerOSCollector {
+	c := &conntrackCollector{topN: topN}
+	c.init()
+	return c
+}
+
+func (c *conntrackCollector) init() {
+	c.protocols = []string{"tcp", "udp", "icmp", "gre"}
+	c.tcpStates = []string{"syn-sent", "syn-received", "established", "fin-wait", "close-wait", "last-ack", "time-wait", "close"}
+	c.props = []string{"enabled", "max-entries", "total-entries"}
+
+	const prefix = "conntrack"
+	labelNames := []string{"name", "address"}
+
+	c.descriptions = map[string]*prometheus.Desc{
+		"enabled":       description(prefix, "enabled", "connection tracking setting (no = 0, yes = 1, auto = 2: enabled only if a rule needs it)", labelNames),
+		"max-entries":   description(prefix, "max_entries", "maximum number of entries in the connection tracking table", labelNames),
+		"total-entries": description(prefix, "entries", "number of entries in the connection tracking table", labelNames),
+		"protocol":      description(prefix, "connections", "number of IPv4 connections per protocol", append(labelNames, "protocol")),
+		"tcp-state":     description(prefix, "tcp_connections", "number of IPv4 TCP connections per state", append(labelNames, "state")),
+		"src-address":   description(prefix, "src_address_connections", "number of IPv4 connections of the source addresses with the most connections", append(labelNames, "src_address")),
+	}
+}
+
+func (c *conntrackCollector) name() string {
+	return "conntrack"
+}
+
+func (c *conntrackCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descriptions {
+		ch <- d
+	}
+}
+
+func (c *conntrackCollector) collect(ctx *collectorContext) error {
+	err := c.collectTracking(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range c.protocols {
+		err := c.collectCount(c.descriptions["protocol"], p, ctx, "?protocol="+p)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, s := range c.tcpStates {
+		err := c.collectCount(c.descriptions["tcp-state"], s, ctx, "?protocol=tcp", "?tcp-state="+s)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.topN > 0 {
+		return c.collectTopSources(ctx)
+	}
+
+	return nil
+}
+
+func (c *conntrackCollector) collectTracking(ctx *collectorContext) error {
+	reply, err := ctx.client.Run("/ip/firewall/connection/tracking/print", "=.proplist="+strings.Join(c.props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching connection tracking settings")
+		return err
+	}
+
+	if len(reply.Re) == 0 {
+		return nil
+	}
+
+	for _, p := range c.props {
+		value := reply.Re[0].Map[p]
+		if value == "" {
+			continue
+		}
+
+		var v float64
+		var err error
+		if p == "enabled" {
+			v, err = parseConntrackEnabled(value)
+		} else {
+			v, err = strconv.ParseFloat(value, 64)
+		}
+
+		if err != nil {
+			log.WithFields(log.Fields{
+				"device":   ctx.device.Name,
+				"property": p,
+				"value":    value,
+				"error":    err,
+			}).Error("error parsing connection tracking metric value")
+			ctx.parseError()
+			continue
+		}
+
+		ctx.ch <- prometheus.MustNewConstMetric(c.descriptions[p], prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address)
+	}
+
+	return nil
+}
+
+func (c *conntrackCollector) collectCount(desc *prometheus.Desc, label string, ctx *collectorContext, filters ...string) error {
+	reply, err := ctx.client.Run(append(append([]string{"/ip/firewall/connection/print"}, filters...), "=count-only=")...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device":  ctx.device.Name,
+			"filters": strings.Join(filters, " "),
+			"error":   err,
+		}).Error("error fetching connection counts")
+		return err
+	}
+
+	v, err := strconv.ParseFloat(reply.Done.Map["ret"], 64)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device":  ctx.device.Name,
+			"filters": strings.Join(filters, " "),
+			"error":   err,
+		}).Error("error parsing connection counts")
+		return err
+	}
+
+	ctx.ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address, label)
+	return nil
+}
+
+// collectTopSources counts the connections per source address. This requires
+// reading all connections, so it is only done if enabled.
+func (c *conntrackCollector) collectTopSources(ctx *collectorContext) error {
+	reply, err := ctx.client.Run("/ip/firewall/connection/print", "=.proplist=src-address")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching connections")
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, re := range reply.Re {
+		counts[hostWithoutPort(re.Map["src-address"])]++
+	}
+
+	addresses := make([]string, 0, len(counts))
+	for a := range counts {
+		addresses = append(addresses, a)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		if counts[addresses[i]] != counts[addresses[j]] {
+			return counts[addresses[i]] > counts[addresses[j]]
+		}
+		return addresses[i] < addresses[j]
+	})
+
+	if len(addresses) > c.topN {
+		addresses = addresses[:c.topN]
+	}
+
+	for _, a := range addresses {
+		ctx.ch <- prometheus.MustNewConstMetric(c.descriptions["src-address"], prometheus.GaugeValue, float64(counts[a]), ctx.device.Name, ctx.device.Address, a)
+	}
+
+	return nil
+}
+
+// parseConntrackEnabled maps the enabled setting to a number. With auto,
+// RouterOS only tracks connections if a firewall rule needs it, so the table
+// may be off.
+func parseConntrackEnabled(value string) (float64, error) {
+	if value == "auto" {
+		return 2, nil
+	}
+
+	return parseBool(value)
+}
+
+// hostWithoutPort removes the port from addresses like 10.0.0.1:51234
+func hostWithoutPort(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	return host
+}
@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"mikrotik-exporter/config"
+)
+
+// customCollector exports the properties of a command defined in the config
+type customCollector struct {
+	cfg          config.CustomCollector
+	props        []string
+	descriptions []*prometheus.Desc
+}
+
+func newCustomCollector(cfg config.CustomCollector) routerOSCollector {
+	c := &customCollector{cfg: cfg}
+	c.init()
+	return c
+}
+
+func (c *customCollector) init() {
+	prefix := metricStringCleanup(c.cfg.Name)
+
+	labelNames := []string{"name", "address"}
+	for _, l := range c.cfg.Labels {
+		labelNames = append(labelNames, metricStringCleanup(l.LabelName()))
+		c.props = append(c.props, l.Property)
+	}
+
+	c.descriptions = make([]*prometheus.Desc, len(c.cfg.Metrics))
+	for i, m := range c.cfg.Metrics {
+		help := m.Help
+		if help == "" {
+			help = m.Property
+		}
+
+		c.descriptions[i] = description(prefix, metricStringCleanup(m.MetricName()), help, labelNames)
+		c.props = append(c.props, m.Property)
+	}
+}
+
+func (c *customCollector) name() string {
+	return c.cfg.Name
+}
+
+func (c *customCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descriptions {
+		ch <- d
+	}
+}
+
+func (c *customCollector) collect(ctx *collectorContext) error {
+	sentence := []string{c.cfg.Command}
+	sentence = append(sentence, c.cfg.Filters...)
+	sentence = append(sentence, "=.proplist="+strings.Join(c.props, ","))
+
+	reply, err := ctx.client.Run(sentence...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"collector": c.cfg.Name,
+			"device":    ctx.device.Name,
+			"error":     err,
+		}).Error("error fetching custom collector metrics")
+		return err
+	}
+
+	for _, re := range reply.Re {
+		labelValues := []string{ctx.device.Name, ctx.device.Address}
+		for _, l := range c.cfg.Labels {
+			labelValues = append(labelValues, re.Map[l.Property])
+		}
+
+		for i, m := range c.cfg.Metrics {
+			c.collectMetric(ctx, c.descriptions[i], &m, re.Map[m.Property], labelValues)
+		}
+	}
+
+	return nil
+}
+
+func (c *customCollector) collectMetric(ctx *collectorContext, desc *prometheus.Desc, m *config.CustomMetric, value string, labelValues []string) {
+	if value == "" {
+		return
+	}
+
+	v, err := parseCustomValue(m.Parser, value)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"collector": c.cfg.Name,
+			"device":    ctx.device.Name,
+			"property":  m.Property,
+			"value":     value,
+			"error":     err,
+		}).Error("error parsing custom metric value")
+		ctx.parseError()
+		return
+	}
+
+	valueType := prometheus.GaugeValue
+	if m.Type == config.MetricTypeCounter {
+		valueType = prometheus.CounterValue
+	}
+
+	ctx.ch <- prometheus.MustNewConstMetric(desc, valueType, v, labelValues...)
+}
+
+func parseCustomValue(parser, value string) (float64, error) {
+	switch parser {
+	case config.ParserDuration:
+		return parseDuration(value)
+	case config.ParserBoolean:
+		return parseBool(value)
+	case config.ParserRate:
+		return parseRate(value)
+	default:
+		return strconv.ParseFloat(value, 64)
+	}
+}
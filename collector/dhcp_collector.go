@@ -25,6 +25,10 @@ func newDHCPCollector() routerOSCollector {
 	return c
 }
 
+func (c *dhcpCollector) name() string {
+	return "dhcp"
+}
+
 func (c *dhcpCollector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.leasesActiveCountDesc
 }
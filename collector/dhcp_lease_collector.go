@@ -27,6 +27,10 @@ func newDHCPLCollector() routerOSCollector {
 	return c
 }
 
+func (c *dhcpLeaseCollector) name() string {
+	return "dhcp-leases"
+}
+
 func (c *dhcpLeaseCollector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.descriptions
 }
@@ -68,6 +72,7 @@ func (c *dhcpLeaseCollector) collectMetric(ctx *collectorContext, re *proto.Sent
 			"value":    re.Map["expires-after"],
 			"error":    err,
 		}).Error("error parsing duration metric value")
+		ctx.parseError()
 		return
 	}
 
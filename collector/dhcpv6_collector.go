@@ -25,6 +25,10 @@ func (c *dhcpv6Collector) init() {
 	c.bindingCountDesc = description(prefix, "binding_count", "number of active bindings per DHCPv6 server", labelNames)
 }
 
+func (c *dhcpv6Collector) name() string {
+	return "dhcpv6"
+}
+
 func (c *dhcpv6Collector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.bindingCountDesc
 }
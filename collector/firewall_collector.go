@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+type firewallCollector struct {
+	ipVersion     string
+	topic         string
+	commentedOnly bool
+	props         []string
+	bytesDesc     *prometheus.Desc
+	packetsDesc   *prometheus.Desc
+}
+
+// firewallRuleKey identifies the series of a rule. Rules sharing all labels
+// are summed up.
+type firewallRuleKey struct {
+	table   string
+	chain   string
+	action  string
+	comment string
+}
+
+type firewallRuleCounters struct {
+	bytes   float64
+	packets float64
+}
+
+// newFirewallCollector creates a collector for the IPv4 firewall rules. With
+// commentedOnly, rules without comment are left out.
+func newFirewallCollector(commentedOnly bool) routerOSCollector {
+	c := &firewallCollector{ipVersion: "4", topic: "ip", commentedOnly: commentedOnly}
+	c.init()
+	return c
+}
+
+// newFirewallV6Collector creates a collector for the IPv6 firewall rules. With
+// commentedOnly, rules without comment are left out.
+func newFirewallV6Collector(commentedOnly bool) routerOSCollector {
+	c := &firewallCollector{ipVersion: "6", topic: "ipv6", commentedOnly: commentedOnly}
+	c.init()
+	return c
+}
+
+func (c *firewallCollector) init() {
+	c.props = []string{"chain", "action", "comment", "bytes", "packets"}
+
+	const prefix = "firewall_rule"
+	labelNames := []string{"name", "address", "ip_version", "table", "chain", "action", "comment"}
+	c.bytesDesc = description(prefix, "bytes", "number of bytes matched by firewall rules", labelNames)
+	c.packetsDesc = description(prefix, "packets", "number of packets matched by firewall rules", labelNames)
+}
+
+func (c *firewallCollector) name() string {
+	if c.ipVersion == "6" {
+		return "firewallv6"
+	}
+
+	return "firewall"
+}
+
+func (c *firewallCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bytesDesc
+	ch <- c.packetsDesc
+}
+
+// tables returns the firewall tables of the device. IPv6 NAT was added in
+// RouterOS 7.
+func (c *firewallCollector) tables(ctx *collectorContext) []string {
+	if c.ipVersion == "4" || capIPv6NAT.supportedBy(ctx.version) {
+		return []string{"filter", "nat", "mangle", "raw"}
+	}
+
+	return []string{"filter", "mangle", "raw"}
+}
+
+func (c *firewallCollector) collect(ctx *collectorContext) error {
+	counters := make(map[firewallRuleKey]*firewallRuleCounters)
+
+	for _, table := range c.tables(ctx) {
+		rules, err := c.fetch(table, ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, re := range rules {
+			c.addRule(table, re, counters, ctx)
+		}
+	}
+
+	keys := make([]firewallRuleKey, 0, len(counters))
+	for k := range counters {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		return a.table+" "+a.chain+" "+a.action+" "+a.comment < b.table+" "+b.chain+" "+b.action+" "+b.comment
+	})
+
+	for _, k := range keys {
+		labelValues := []string{ctx.device.Name, ctx.device.Address, c.ipVersion, k.table, k.chain, k.action, k.comment}
+		ctx.ch <- prometheus.MustNewConstMetric(c.bytesDesc, prometheus.CounterValue, counters[k].bytes, labelValues...)
+		ctx.ch <- prometheus.MustNewConstMetric(c.packetsDesc, prometheus.CounterValue, counters[k].packets, labelValues...)
+	}
+
+	return nil
+}
+
+func (c *firewallCollector) fetch(table string, ctx *collectorContext) ([]*proto.Sentence, error) {
+	reply, err := ctx.client.Run("/"+c.topic+"/firewall/"+table+"/print", "?disabled=false", "=.proplist="+strings.Join(c.props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"ip_version": c.ipVersion,
+			"table":      table,
+			"device":     ctx.device.Name,
+			"error":      err,
+		}).Error("error fetching firewall rules")
+		return nil, err
+	}
+
+	return reply.Re, nil
+}
+
+func (c *firewallCollector) addRule(table string, re *proto.Sentence, counters map[firewallRuleKey]*firewallRuleCounters, ctx *collectorContext) {
+	comment := re.Map["comment"]
+	if c.commentedOnly && comment == "" {
+		return
+	}
+
+	bytes, packets, err := parseFirewallCounters(re)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device":  ctx.device.Name,
+			"table":   table,
+			"chain":   re.Map["chain"],
+			"comment": comment,
+			"error":   err,
+		}).Error("error parsing firewall rule counters")
+		ctx.parseError()
+		return
+	}
+
+	k := firewallRuleKey{table, re.Map["chain"], re.Map["action"], comment}
+	if counters[k] == nil {
+		counters[k] = &firewallRuleCounters{}
+	}
+	counters[k].bytes += bytes
+	counters[k].packets += packets
+}
+
+func parseFirewallCounters(re *proto.Sentence) (float64, float64, error) {
+	bytes, err := strconv.ParseFloat(re.Map["bytes"], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	packets, err := strconv.ParseFloat(re.Map["packets"], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return bytes, packets, nil
+}
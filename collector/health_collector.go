@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+// healthSensor is a single reading of /system/health. RouterOS 6 reports all
+// readings as properties of one item, RouterOS 7 reports one item per sensor
+// with the unit in a separate property.
+type healthSensor struct {
+	name  string
+	value string
+	unit  string
+}
+
+type healthCollector struct {
+	descriptions map[string]*prometheus.Desc
+}
+
+func newHealthCollector() routerOSCollector {
+	c := &healthCollector{}
+	c.init()
+	return c
+}
+
+func (c *healthCollector) init() {
+	const prefix = "health"
+	labelNames := []string{"name", "address", "sensor"}
+
+	c.descriptions = map[string]*prometheus.Desc{
+		"temperature": description(prefix, "temperature_celsius", "temperature reported by the sensor in degrees celsius", labelNames),
+		"voltage":     description(prefix, "voltage_volts", "voltage reported by the sensor in volts", labelNames),
+		"current":     description(prefix, "current_amperes", "current reported by the sensor in amperes", labelNames),
+		"power":       description(prefix, "power_watts", "power consumption reported by the sensor in watts", labelNames),
+		"fan-speed":   description(prefix, "fan_speed_rpm", "fan speed reported by the sensor in revolutions per minute", labelNames),
+		"fan-ok":      description(prefix, "fan_ok", "fan state is ok (ok = 1)", labelNames),
+		"psu-ok":      description(prefix, "psu_ok", "power supply state is ok (ok = 1)", labelNames),
+	}
+}
+
+func (c *healthCollector) name() string {
+	return "health"
+}
+
+func (c *healthCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descriptions {
+		ch <- d
+	}
+}
+
+func (c *healthCollector) collect(ctx *collectorContext) error {
+	reply, err := ctx.client.Run("/system/health/print")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"error":  err,
+		}).Error("error fetching system health metrics")
+		return err
+	}
+
+	for _, s := range healthSensors(reply.Re) {
+		c.collectForSensor(s, ctx)
+	}
+
+	return nil
+}
+
+// healthSensors returns the readings of both layouts of /system/health
+func healthSensors(items []*proto.Sentence) []healthSensor {
+	sensors := []healthSensor{}
+
+	for _, re := range items {
+		if _, found := re.Map["value"]; found {
+			sensors = append(sensors, healthSensor{name: re.Map["name"], value: re.Map["value"], unit: re.Map["type"]})
+			continue
+		}
+
+		names := make([]string, 0, len(re.Map))
+		for name := range re.Map {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			sensors = append(sensors, healthSensor{name: name, value: re.Map[name], unit: healthUnitForProperty(name)})
+		}
+	}
+
+	return sensors
+}
+
+// healthUnitForProperty returns the unit of a RouterOS 6 health property,
+// which is implied by its name
+func healthUnitForProperty(name string) string {
+	switch {
+	case strings.Contains(name, "temperature"):
+		return "C"
+	case strings.HasSuffix(name, "voltage"):
+		return "V"
+	case strings.HasSuffix(name, "current"):
+		return "mA"
+	case name == "power-consumption":
+		return "W"
+	case strings.HasSuffix(name, "-speed"):
+		return "RPM"
+	}
+
+	return ""
+}
+
+func (c *healthCollector) collectForSensor(s healthSensor, ctx *collectorContext) {
+	metric := healthMetric(s)
+	if metric == "" || s.value == "" {
+		return
+	}
+
+	v, err := parseHealthValue(metric, s)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"sensor": s.name,
+			"value":  s.value,
+			"error":  err,
+		}).Error("error parsing system health metric value")
+		ctx.parseError()
+		return
+	}
+
+	ctx.ch <- prometheus.MustNewConstMetric(c.descriptions[metric], prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address, s.name)
+}
+
+// healthMetric returns the metric of a sensor, or an empty string for
+// readings which are not exported (e.g. fan-mode)
+func healthMetric(s healthSensor) string {
+	switch strings.TrimPrefix(s.unit, "°") {
+	case "C":
+		return "temperature"
+	case "V":
+		return "voltage"
+	case "A", "mA":
+		return "current"
+	case "W":
+		return "power"
+	case "RPM":
+		return "fan-speed"
+	}
+
+	if strings.HasSuffix(s.name, "-state") {
+		if strings.HasPrefix(s.name, "psu") {
+			return "psu-ok"
+		}
+
+		if strings.HasPrefix(s.name, "fan") {
+			return "fan-ok"
+		}
+	}
+
+	return ""
+}
+
+func parseHealthValue(metric string, s healthSensor) (float64, error) {
+	if metric == "psu-ok" || metric == "fan-ok" {
+		if s.value == "ok" {
+			return 1, nil
+		}
+
+		return 0, nil
+	}
+
+	v, err := strconv.ParseFloat(s.value, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.unit == "mA" {
+		v /= 1000
+	}
+
+	return v, nil
+}
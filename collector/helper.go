@@ -3,6 +3,7 @@ package collector
 import (
 	"fmt"
 	"math"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
@@ -10,9 +11,11 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"mikrotik-exporter/config"
 )
 
 var durationRegex *regexp.Regexp
+var millisecondsRegex *regexp.Regexp
 var durationParts [5]time.Duration
 var wirelessRateRegex *regexp.Regexp
 
@@ -20,6 +23,9 @@ func init() {
 	durationRegex = regexp.MustCompile(`(?:(\d*)w)?(?:(\d*)d)?(?:(\d*)h)?(?:(\d*)m)?(?:(\d*)s)?`)
 	durationParts = [5]time.Duration{time.Hour * 168, time.Hour * 24, time.Hour, time.Minute, time.Second}
 
+	// RouterOS 7 appends milliseconds to some durations, e.g. 3s450ms
+	millisecondsRegex = regexp.MustCompile(`^(.*?)(\d+)ms$`)
+
 	wirelessRateRegex = regexp.MustCompile(`([\d.]+)Mbps.*`)
 }
 
@@ -45,8 +51,39 @@ func description(prefix, name, helpText string, labelNames []string) *prometheus
 	)
 }
 
+// dialAddress joins the address of a device with its API port. The port of the
+// device takes precedence over a port in the address, which in turn takes
+// precedence over the default port. IPv6 addresses may be given with or without
+// brackets.
+func dialAddress(d *config.Device, defaultPort string) string {
+	host := d.Address
+	port := defaultPort
+
+	if h, p, err := net.SplitHostPort(d.Address); err == nil {
+		host = h
+		port = p
+	} else {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+
+	if d.Port != 0 {
+		port = strconv.Itoa(d.Port)
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
 func splitStringToFloats(metric string) (float64, float64, error) {
-	strs := strings.Split(metric, ",")
+	return splitStringToFloatsWithSeparator(metric, ",")
+}
+
+// splitStringToFloatsWithSeparator parses pairs of values like the
+// upload/download values of queues
+func splitStringToFloatsWithSeparator(metric, separator string) (float64, float64, error) {
+	strs := strings.Split(metric, separator)
+	if len(strs) < 2 {
+		return math.NaN(), math.NaN(), fmt.Errorf("invalid value pair %q", metric)
+	}
 
 	m1, err := strconv.ParseFloat(strs[0], 64)
 	if err != nil {
@@ -62,6 +99,15 @@ func splitStringToFloats(metric string) (float64, float64, error) {
 func parseDuration(duration string) (float64, error) {
 	var u time.Duration
 
+	if m := millisecondsRegex.FindStringSubmatch(duration); m != nil {
+		v, err := strconv.Atoi(m[2])
+		if err != nil {
+			return 0, err
+		}
+		u = time.Duration(v) * time.Millisecond
+		duration = m[1]
+	}
+
 	reMatch := durationRegex.FindAllStringSubmatch(duration, -1)
 
 	// should get one and only one match back on the regex
@@ -122,3 +168,41 @@ func parseWirelessRate(rate string) (float64, error) {
 	}
 	return 0, nil
 }
+
+func parseBool(value string) (float64, error) {
+	switch value {
+	case "true", "yes", "up":
+		return 1, nil
+	case "false", "no", "down":
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("invalid boolean value %s", value)
+}
+
+var rateUnits = []struct {
+	suffix string
+	factor float64
+}{
+	{"Gbps", 1e9},
+	{"Mbps", 1e6},
+	{"kbps", 1e3},
+	{"bps", 1},
+}
+
+// parseRate converts rates like 10Mbps or 1.5Gbps to bits per second
+func parseRate(rate string) (float64, error) {
+	for _, u := range rateUnits {
+		if !strings.HasSuffix(rate, u.suffix) {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSuffix(rate, u.suffix), 64)
+		if err != nil {
+			return 0, err
+		}
+		return v * u.factor, nil
+	}
+
+	return strconv.ParseFloat(rate, 64)
+}
@@ -3,6 +3,7 @@ package collector
 import (
 	"github.com/stretchr/testify/assert"
 	"math"
+	"mikrotik-exporter/config"
 	"testing"
 	"time"
 )
@@ -56,6 +57,11 @@ func TestSplitStringToFloats(t *testing.T) {
 			isNaN:    true,
 			hasError: true,
 		},
+		{
+			input:    "1.2",
+			isNaN:    true,
+			hasError: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -79,6 +85,16 @@ func TestSplitStringToFloats(t *testing.T) {
 	}
 }
 
+func TestSplitStringToFloatsWithSeparator(t *testing.T) {
+	f1, f2, err := splitStringToFloatsWithSeparator("1000/2000", "/")
+	assert.NoError(t, err)
+	assert.Equal(t, 1000.0, f1)
+	assert.Equal(t, 2000.0, f2)
+
+	_, _, err = splitStringToFloatsWithSeparator("1000,2000", "/")
+	assert.Error(t, err)
+}
+
 func TestParseDuration(t *testing.T) {
 	var testCases = []struct {
 		input    string
@@ -105,6 +121,16 @@ func TestParseDuration(t *testing.T) {
 			4786440,
 			false,
 		},
+		{
+			"1h2m3s450ms",
+			3723.45,
+			false,
+		},
+		{
+			"450ms",
+			0.45,
+			false,
+		},
 		{
 			"59",
 			0,
@@ -234,3 +260,105 @@ func TestParseDatetime(t *testing.T) {
 		assert.Equal(t, testCase.output, tt)
 	}
 }
+
+func TestDialAddress(t *testing.T) {
+	var testCases = []struct {
+		address string
+		port    int
+		output  string
+	}{
+		{
+			"192.168.1.1",
+			0,
+			"192.168.1.1:8728",
+		},
+		{
+			"192.168.1.1:18728",
+			0,
+			"192.168.1.1:18728",
+		},
+		{
+			"192.168.1.1:18728",
+			28728,
+			"192.168.1.1:28728",
+		},
+		{
+			"router.example.com",
+			18728,
+			"router.example.com:18728",
+		},
+		{
+			"2001:db8::1",
+			0,
+			"[2001:db8::1]:8728",
+		},
+		{
+			"[2001:db8::1]",
+			18728,
+			"[2001:db8::1]:18728",
+		},
+		{
+			"[2001:db8::1]:18728",
+			0,
+			"[2001:db8::1]:18728",
+		},
+	}
+
+	for _, testCase := range testCases {
+		d := &config.Device{Address: testCase.address, Port: testCase.port}
+		assert.Equal(t, testCase.output, dialAddress(d, apiPort))
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected float64
+		hasError bool
+	}{
+		{"true", 1, false},
+		{"yes", 1, false},
+		{"false", 0, false},
+		{"no", 0, false},
+		{"up", 1, false},
+		{"down", 0, false},
+		{"maybe", 0, true},
+	}
+
+	for _, tc := range testCases {
+		v, err := parseBool(tc.input)
+		if tc.hasError {
+			assert.Error(t, err, tc.input)
+			continue
+		}
+
+		assert.NoError(t, err, tc.input)
+		assert.Equal(t, tc.expected, v, tc.input)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected float64
+		hasError bool
+	}{
+		{"512bps", 512, false},
+		{"100kbps", 100e3, false},
+		{"10Mbps", 10e6, false},
+		{"1.5Gbps", 1.5e9, false},
+		{"1000", 1000, false},
+		{"fastbps", 0, true},
+	}
+
+	for _, tc := range testCases {
+		v, err := parseRate(tc.input)
+		if tc.hasError {
+			assert.Error(t, err, tc.input)
+			continue
+		}
+
+		assert.NoError(t, err, tc.input)
+		assert.Equal(t, tc.expected, v, tc.input)
+	}
+}
@@ -31,6 +31,10 @@ func (c *interfaceCollector) init() {
 	}
 }
 
+func (c *interfaceCollector) name() string {
+	return "interface"
+}
+
 func (c *interfaceCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -117,6 +121,7 @@ func (c *interfaceCollector) collectMetricForProperty(property, iface, comment,
 					"value":     value,
 					"error":     err,
 				}).Error("error parsing interface duration metric value")
+				ctx.parseError()
 				return
 			}
 
@@ -135,6 +140,7 @@ func (c *interfaceCollector) collectMetricForProperty(property, iface, comment,
 					"value":     value,
 					"error":     err,
 				}).Error("error parsing interface metric value")
+				ctx.parseError()
 				return
 			}
 		}
@@ -33,6 +33,10 @@ func (c *ipsecPeersCollector) init() {
 	}
 }
 
+func (c *ipsecPeersCollector) name() string {
+	return "ipsec-peers"
+}
+
 func (c *ipsecPeersCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -86,6 +90,7 @@ func (c *ipsecPeersCollector) collectMetricForProperty(property, id, remoteAddre
 			"value":    re.Map[property],
 			"error":    err,
 		}).Error("error parsing ipsec peers metric value")
+		ctx.parseError()
 		return
 	}
 
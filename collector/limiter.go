@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var queueWaitDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "queue_wait_seconds"),
+	"mikrotik_exporter: time the device waited for a free slot before being collected",
+	[]string{"device"},
+	nil,
+)
+
+// Limiter limits the number of devices collected at the same time. A single
+// limiter can be shared by collectors, e.g. for /metrics and /probe requests.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter creates a limiter allowing max devices to be collected at the
+// same time
+func NewLimiter(max int) *Limiter {
+	return &Limiter{
+		slots: make(chan struct{}, max),
+	}
+}
+
+// acquire waits for a free slot until the deadline, if there is one. It
+// returns false if no slot became free in time.
+func (l *Limiter) acquire(deadline time.Time) bool {
+	if deadline.IsZero() {
+		l.slots <- struct{}{}
+		return true
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (l *Limiter) release() {
+	<-l.slots
+}
+
+// waitToStart delays the start of a device by a random jitter and waits for a
+// free slot of the limiter. It returns the time waited for the slot and a
+// function releasing the slot. If the deadline passes while waiting, the
+// device is collected without a slot, which lets all collectors fail right
+// away with the scrape timeout.
+func (c *collector) waitToStart(deadline time.Time) (time.Duration, func()) {
+	if c.jitter > 0 {
+		delay := time.Duration(rand.Int63n(int64(c.jitter)))
+		if !deadline.IsZero() {
+			if remaining := time.Until(deadline); remaining < delay {
+				delay = remaining
+			}
+		}
+		time.Sleep(delay)
+	}
+
+	if c.limiter == nil {
+		return 0, func() {}
+	}
+
+	begin := time.Now()
+	if !c.limiter.acquire(deadline) {
+		return time.Since(begin), func() {}
+	}
+
+	return time.Since(begin), c.limiter.release
+}
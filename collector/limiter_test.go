@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"mikrotik-exporter/config"
+)
+
+func TestLimiterShouldWaitForFreeSlot(t *testing.T) {
+	l := NewLimiter(1)
+
+	assert.True(t, l.acquire(time.Time{}))
+	assert.False(t, l.acquire(time.Now().Add(20*time.Millisecond)))
+
+	l.release()
+	assert.True(t, l.acquire(time.Now().Add(20*time.Millisecond)))
+}
+
+func TestCollectShouldReportQueueWait(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	l := NewLimiter(1)
+	l.acquire(time.Time{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l.release()
+	}()
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg, WithLimiter(l), WithJitter(10*time.Millisecond)))
+
+	assert.True(t, metrics["mikrotik_scrape_queue_wait_seconds{}"] >= 0.04, "queue wait")
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+}
+
+func TestCollectShouldTimeOutWaitingForSlot(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	l := NewLimiter(1)
+	l.acquire(time.Time{})
+	defer l.release()
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg, WithLimiter(l), WithScrapeTimeout(50*time.Millisecond)))
+
+	assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+	assert.Equal(t, 0, s.Logins())
+}
@@ -29,6 +29,10 @@ func (c *monitorCollector) init() {
 	}
 }
 
+func (c *monitorCollector) name() string {
+	return "monitor"
+}
+
 func (c *monitorCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -36,6 +36,10 @@ func newOpticsCollector() routerOSCollector {
 	}
 }
 
+func (c *opticsCollector) name() string {
+	return "optics"
+}
+
 func (c *opticsCollector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.rxStatusDesc
 	ch <- c.txStatusDesc
@@ -111,6 +115,7 @@ func (c *opticsCollector) collectMetricsForInterface(name string, se *proto.Sent
 				"property":  prop,
 				"error":     err,
 			}).Error("error parsing interface monitor metric")
+			ctx.parseError()
 			return
 		}
 
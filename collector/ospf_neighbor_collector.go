@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"sort"
 	"strconv"
 	"strings"
 
@@ -9,88 +10,300 @@ import (
 	"gopkg.in/routeros.v2/proto"
 )
 
+// ospfNeighborStates maps neighbor states to the values of ospfNbrState in
+// the OSPF MIB (RFC 4750)
+var ospfNeighborStates = map[string]float64{
+	"down":     1,
+	"attempt":  2,
+	"init":     3,
+	"2-way":    4,
+	"exstart":  5,
+	"exchange": 6,
+	"loading":  7,
+	"full":     8,
+}
+
+// ospfInterfaceStates maps interface states to the values of ospfIfState in
+// the OSPF MIB (RFC 4750)
+var ospfInterfaceStates = map[string]float64{
+	"down":           1,
+	"loopback":       2,
+	"waiting":        3,
+	"point-to-point": 4,
+	"ptp":            4,
+	"dr":             5,
+	"backup":         6,
+	"bdr":            6,
+	"dr-other":       7,
+	"drother":        7,
+}
+
+// ospfMenu is a menu with neighbors, interfaces and LSAs of one or more OSPF
+// versions
+type ospfMenu struct {
+	path string
+
+	// version is the OSPF version of all entries, or empty if it is read
+	// from the instance of each entry (RouterOS 7)
+	version string
+
+	drProp  string
+	bdrProp string
+
+	// optional menus are skipped if the device does not know them, e.g.
+	// /routing/ospf-v3 if the ipv6 package is disabled
+	optional bool
+}
+
 type ospfNeighborCollector struct {
-	props        []string
-	descriptions map[string]*prometheus.Desc
+	menus      []ospfMenu
+	capability capability
+
+	neighborDescs  map[string]*prometheus.Desc
+	interfaceDescs map[string]*prometheus.Desc
+	lsaCountDesc   *prometheus.Desc
 }
 
+// newOSPFNeighborCollector collects OSPFv2 and OSPFv3 of RouterOS 6, which
+// have separate menus
 func newOSPFNeighborCollector() routerOSCollector {
-	c := &ospfNeighborCollector{}
+	c := &ospfNeighborCollector{
+		menus: []ospfMenu{
+			{path: "/routing/ospf", version: "2", drProp: "dr-address", bdrProp: "backup-dr-address"},
+			{path: "/routing/ospf-v3", version: "3", drProp: "dr-id", bdrProp: "backup-dr-id", optional: true},
+		},
+		capability: capRoutingV6,
+	}
+	c.init()
+	return c
+}
+
+// newOSPFv7NeighborCollector collects OSPF of RouterOS 7, where instances of
+// both versions share one menu
+func newOSPFv7NeighborCollector() routerOSCollector {
+	c := &ospfNeighborCollector{
+		menus: []ospfMenu{
+			{path: "/routing/ospf", drProp: "dr", bdrProp: "bdr"},
+		},
+		capability: capRoutingV7,
+	}
 	c.init()
 	return c
 }
 
 func (c *ospfNeighborCollector) init() {
-	c.props = []string{"instance", "router-id", "address", "interface", "state", "state-changes"}
+	neighborLabels := []string{"name", "address", "ospf_version", "instance", "area", "router_id", "neighbor_address", "interface"}
+
+	c.neighborDescs = map[string]*prometheus.Desc{
+		"state":         description("ospf_neighbor", "state", "OSPF neighbor state as in the OSPF MIB (1 = down ... 8 = full)", neighborLabels),
+		"is_full":       description("ospf_neighbor", "is_full", "OSPF neighbor adjacency is full (full = 1)", neighborLabels),
+		"state-changes": description("ospf_neighbor", "state_changes", "OSPF neighbor state changes counter", neighborLabels),
+		"priority":      description("ospf_neighbor", "priority", "OSPF neighbor router priority", neighborLabels),
+		"is_dr":         description("ospf_neighbor", "is_dr", "OSPF neighbor is the designated router (dr = 1)", neighborLabels),
+		"is_bdr":        description("ospf_neighbor", "is_bdr", "OSPF neighbor is the backup designated router (bdr = 1)", neighborLabels),
+		"timeout":       description("ospf_neighbor", "dead_timer_seconds", "time until the OSPF neighbor is declared down", neighborLabels),
+	}
 
-	const prefix = "ospf_neighbor"
-	labelNames := []string{"name", "address", "instance", "router_id", "neighbor_address", "interface", "state"}
+	interfaceLabels := []string{"name", "address", "ospf_version", "instance", "area", "interface"}
+	c.interfaceDescs = map[string]*prometheus.Desc{
+		"state":    description("ospf_interface", "state", "OSPF interface state as in the OSPF MIB (1 = down ... 7 = dr-other)", interfaceLabels),
+		"cost":     description("ospf_interface", "cost", "OSPF interface cost", interfaceLabels),
+		"priority": description("ospf_interface", "priority", "OSPF interface router priority", interfaceLabels),
+	}
 
-	c.descriptions = make(map[string]*prometheus.Desc)
-	c.descriptions["state-changes"] = description(prefix, "state_changes", "OSPF neighbor state changes counter", labelNames)
+	c.lsaCountDesc = description("ospf_lsa", "count", "number of LSAs in the OSPF database per area and type", []string{"name", "address", "ospf_version", "instance", "area", "type"})
+}
+
+func (c *ospfNeighborCollector) name() string {
+	return "ospf-neighbor"
+}
+
+func (c *ospfNeighborCollector) supportedBy(v routerOSVersion) bool {
+	return c.capability.supportedBy(v)
 }
 
 func (c *ospfNeighborCollector) describe(ch chan<- *prometheus.Desc) {
-	for _, d := range c.descriptions {
+	for _, d := range c.neighborDescs {
 		ch <- d
 	}
+
+	for _, d := range c.interfaceDescs {
+		ch <- d
+	}
+
+	ch <- c.lsaCountDesc
 }
 
 func (c *ospfNeighborCollector) collect(ctx *collectorContext) error {
-	stats, err := c.fetch(ctx)
+	for _, m := range c.menus {
+		err := c.collectForMenu(m, ctx)
+		if err != nil && m.optional && isUnknownCommand(err) {
+			log.WithFields(log.Fields{
+				"device": ctx.device.Name,
+				"menu":   m.path,
+			}).Debug("skipping ospf menu unknown to the device")
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ospfNeighborCollector) collectForMenu(m ospfMenu, ctx *collectorContext) error {
+	versions := map[string]string{}
+	if m.version == "" {
+		instances, err := c.fetch(m, "instance", []string{"name", "version"}, ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, re := range instances {
+			versions[re.Map["name"]] = re.Map["version"]
+		}
+	}
+
+	version := func(re *proto.Sentence) string {
+		if m.version != "" {
+			return m.version
+		}
+
+		return versions[re.Map["instance"]]
+	}
+
+	neighbors, err := c.fetch(m, "neighbor", []string{"instance", "area", "router-id", "address", "interface", "state", "state-changes", "priority", m.drProp, m.bdrProp, "timeout"}, ctx)
 	if err != nil {
 		return err
 	}
 
-	for _, re := range stats {
-		c.collectForStat(re, ctx)
+	for _, re := range neighbors {
+		c.collectForNeighbor(m, version(re), re, ctx)
+	}
+
+	interfaces, err := c.fetch(m, "interface", []string{"instance", "area", "interface", "state", "cost", "priority"}, ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range interfaces {
+		c.collectForInterface(version(re), re, ctx)
+	}
+
+	lsas, err := c.fetch(m, "lsa", []string{"instance", "area", "type"}, ctx)
+	if err != nil {
+		return err
 	}
 
+	c.collectLSACounts(lsas, version, ctx)
+
 	return nil
 }
 
-func (c *ospfNeighborCollector) fetch(ctx *collectorContext) ([]*proto.Sentence, error) {
-	reply, err := ctx.client.Run("/routing/ospf/neighbor/print", "=.proplist="+strings.Join(c.props, ","))
+func (c *ospfNeighborCollector) fetch(m ospfMenu, item string, props []string, ctx *collectorContext) ([]*proto.Sentence, error) {
+	menu := m.path + "/" + item
+	reply, err := ctx.client.Run(menu+"/print", "=.proplist="+strings.Join(props, ","))
+	if err != nil && m.optional && isUnknownCommand(err) {
+		return nil, err
+	}
+
 	if err != nil {
 		log.WithFields(log.Fields{
 			"device": ctx.device.Name,
+			"menu":   menu,
 			"error":  err,
-		}).Error("error fetching ospf neighbor metrics")
+		}).Error("error fetching ospf metrics")
 		return nil, err
 	}
 
 	return reply.Re, nil
 }
 
-func (c *ospfNeighborCollector) collectForStat(re *proto.Sentence, ctx *collectorContext) {
-	instance := re.Map["instance"]
+func (c *ospfNeighborCollector) collectForNeighbor(m ospfMenu, version string, re *proto.Sentence, ctx *collectorContext) {
 	routerID := re.Map["router-id"]
 	neighborAddress := re.Map["address"]
-	neighborInterface := re.Map["interface"]
-	state := re.Map["state"]
+	labelValues := []string{ctx.device.Name, ctx.device.Address, version, re.Map["instance"], re.Map["area"], routerID, neighborAddress, re.Map["interface"]}
+
+	state := ospfNeighborStates[strings.ToLower(re.Map["state"])]
+	full := 0.0
+	if state == ospfNeighborStates["full"] {
+		full = 1
+	}
+	ctx.ch <- prometheus.MustNewConstMetric(c.neighborDescs["state"], prometheus.GaugeValue, state, labelValues...)
+	ctx.ch <- prometheus.MustNewConstMetric(c.neighborDescs["is_full"], prometheus.GaugeValue, full, labelValues...)
 
-	for _, p := range c.props[5:] {
-		c.collectMetricForProperty(p, instance, routerID, neighborAddress, neighborInterface, state, re, ctx)
+	isRouter := func(id string) float64 {
+		if id != "" && (id == neighborAddress || id == routerID) {
+			return 1
+		}
+		return 0
+	}
+	ctx.ch <- prometheus.MustNewConstMetric(c.neighborDescs["is_dr"], prometheus.GaugeValue, isRouter(re.Map[m.drProp]), labelValues...)
+	ctx.ch <- prometheus.MustNewConstMetric(c.neighborDescs["is_bdr"], prometheus.GaugeValue, isRouter(re.Map[m.bdrProp]), labelValues...)
+
+	c.collectMetricForProperty(c.neighborDescs["state-changes"], prometheus.CounterValue, "state-changes", re, labelValues, ctx)
+	c.collectMetricForProperty(c.neighborDescs["priority"], prometheus.GaugeValue, "priority", re, labelValues, ctx)
+	c.collectMetricForProperty(c.neighborDescs["timeout"], prometheus.GaugeValue, "timeout", re, labelValues, ctx)
+}
+
+func (c *ospfNeighborCollector) collectForInterface(version string, re *proto.Sentence, ctx *collectorContext) {
+	labelValues := []string{ctx.device.Name, ctx.device.Address, version, re.Map["instance"], re.Map["area"], re.Map["interface"]}
+
+	if state, found := ospfInterfaceStates[strings.ToLower(re.Map["state"])]; found {
+		ctx.ch <- prometheus.MustNewConstMetric(c.interfaceDescs["state"], prometheus.GaugeValue, state, labelValues...)
+	}
+
+	c.collectMetricForProperty(c.interfaceDescs["cost"], prometheus.GaugeValue, "cost", re, labelValues, ctx)
+	c.collectMetricForProperty(c.interfaceDescs["priority"], prometheus.GaugeValue, "priority", re, labelValues, ctx)
+}
+
+func (c *ospfNeighborCollector) collectLSACounts(lsas []*proto.Sentence, version func(re *proto.Sentence) string, ctx *collectorContext) {
+	counts := make(map[[4]string]int)
+	for _, re := range lsas {
+		counts[[4]string{version(re), re.Map["instance"], re.Map["area"], re.Map["type"]}]++
+	}
+
+	keys := make([][4]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.Join(keys[i][:], " ") < strings.Join(keys[j][:], " ")
+	})
+
+	for _, k := range keys {
+		ctx.ch <- prometheus.MustNewConstMetric(c.lsaCountDesc, prometheus.GaugeValue, float64(counts[k]), ctx.device.Name, ctx.device.Address, k[0], k[1], k[2], k[3])
 	}
 }
 
-func (c *ospfNeighborCollector) collectMetricForProperty(property, instance, routerID, neighborAddress, neighborInterface, state string, re *proto.Sentence, ctx *collectorContext) {
-	desc := c.descriptions[property]
-	v, err := c.parseValueForProperty(property, re.Map[property])
+func (c *ospfNeighborCollector) collectMetricForProperty(desc *prometheus.Desc, valueType prometheus.ValueType, property string, re *proto.Sentence, labelValues []string, ctx *collectorContext) {
+	value := re.Map[property]
+	if value == "" {
+		return
+	}
+
+	v, err := c.parseValueForProperty(property, value)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"device":    ctx.device.Name,
-			"router_id": routerID,
+			"router_id": re.Map["router-id"],
 			"property":  property,
-			"value":     re.Map[property],
+			"value":     value,
 			"error":     err,
 		}).Error("error parsing ospf neighbor metric value")
+		ctx.parseError()
 		return
 	}
 
-	ctx.ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address, instance, routerID, neighborAddress, neighborInterface, state)
+	ctx.ch <- prometheus.MustNewConstMetric(desc, valueType, v, labelValues...)
 }
 
 func (c *ospfNeighborCollector) parseValueForProperty(property, value string) (float64, error) {
+	if property == "timeout" {
+		return parseDuration(value)
+	}
+
 	return strconv.ParseFloat(value, 64)
 }
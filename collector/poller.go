@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"mikrotik-exporter/config"
+)
+
+var (
+	lastSuccessfulPollDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "last_successful_poll_timestamp_seconds"),
+		"mikrotik_exporter: time of the last successful poll of a collector",
+		[]string{"device", "collector"},
+		nil,
+	)
+	pollStalenessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "poll", "staleness_seconds"),
+		"mikrotik_exporter: age of the metrics served for a collector",
+		[]string{"device", "collector"},
+		nil,
+	)
+)
+
+// pollCache keeps the results of the latest background poll of every device
+type pollCache struct {
+	mu      sync.RWMutex
+	devices map[string]*devicePoll
+}
+
+type devicePoll struct {
+	collectors map[string]*collectorPoll
+	device     []prometheus.Metric
+}
+
+// collectorPoll holds the metrics of the last successful poll of a collector.
+// A failed poll keeps those metrics, their age is exported as staleness.
+type collectorPoll struct {
+	metrics     []prometheus.Metric
+	duration    time.Duration
+	err         error
+	lastSuccess time.Time
+}
+
+func newPollCache() *pollCache {
+	return &pollCache{
+		devices: make(map[string]*devicePoll),
+	}
+}
+
+func (p *pollCache) device(name string) *devicePoll {
+	dp, found := p.devices[name]
+	if !found {
+		dp = &devicePoll{collectors: make(map[string]*collectorPoll)}
+		p.devices[name] = dp
+	}
+
+	return dp
+}
+
+func (p *pollCache) collectorDone(d *config.Device, co routerOSCollector, metrics []prometheus.Metric, duration time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dp := p.device(d.Name)
+	cp, found := dp.collectors[co.name()]
+	if !found {
+		cp = &collectorPoll{}
+		dp.collectors[co.name()] = cp
+	}
+
+	cp.duration = duration
+	cp.err = err
+	if err == nil {
+		cp.metrics = metrics
+		cp.lastSuccess = time.Now()
+	}
+}
+
+func (p *pollCache) deviceDone(d *config.Device, metrics []prometheus.Metric) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.device(d.Name).device = metrics
+}
+
+// collect sends the latest results of all devices. Devices not polled yet
+// are left out.
+func (p *pollCache) collect(ch chan<- prometheus.Metric) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	now := time.Now()
+	for device, dp := range p.devices {
+		for name, cp := range dp.collectors {
+			for _, m := range cp.metrics {
+				ch <- m
+			}
+
+			ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, cp.duration.Seconds(), device, name)
+			ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, successValue(cp.err), device, name)
+
+			if !cp.lastSuccess.IsZero() {
+				ch <- prometheus.MustNewConstMetric(lastSuccessfulPollDesc, prometheus.GaugeValue, float64(cp.lastSuccess.UnixNano())/1e9, device, name)
+				ch <- prometheus.MustNewConstMetric(pollStalenessDesc, prometheus.GaugeValue, now.Sub(cp.lastSuccess).Seconds(), device, name)
+			}
+		}
+
+		for _, m := range dp.device {
+			ch <- m
+		}
+	}
+}
+
+// startPolling polls every device in the background until the context of the
+// collector is done
+func (c *collector) startPolling() {
+	c.polls = newPollCache()
+
+	for _, d := range c.devices {
+		interval := c.pollInterval
+		if d.PollInterval > 0 {
+			interval = d.PollInterval
+		}
+
+		go c.poll(d, interval)
+	}
+}
+
+func (c *collector) poll(d config.Device, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		timeout := interval
+		if c.scrapeTimeout > 0 && c.scrapeTimeout < timeout {
+			timeout = c.scrapeTimeout
+		}
+
+		c.collectForDevice(d, time.Now().Add(timeout), c.polls)
+
+		select {
+		case <-c.pollCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"mikrotik-exporter/collector/routerostest"
+	"mikrotik-exporter/config"
+)
+
+// waitForMetric scrapes until the metric has the expected value
+func waitForMetric(t *testing.T, c *collector, name string, expected float64) map[string]float64 {
+	timeout := time.After(5 * time.Second)
+	for {
+		metrics := collectMetrics(t, c)
+		if v, found := metrics[name]; found && v == expected {
+			return metrics
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s to be %v, got %v", name, expected, metrics)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestPollingShouldServeLatestResults(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	c := newTestCollector(t, cfg, WithPolling(ctx, time.Hour)).(*collector)
+
+	metrics := waitForMetric(t, c, "mikrotik_scrape_collector_success{collector=resource}", 1)
+	assert.Equal(t, 7.0, metrics["mikrotik_system_cpu_load{boardname=RB4011,version=6.45.9 (long-term)}"])
+	assert.InDelta(t, float64(time.Now().Unix()), metrics["mikrotik_last_successful_poll_timestamp_seconds{collector=resource}"], 5)
+	assert.Contains(t, metrics, "mikrotik_poll_staleness_seconds{collector=resource}")
+
+	commands := len(s.Commands())
+	collectMetrics(t, c)
+	assert.Equal(t, commands, len(s.Commands()), "scrapes should not send commands")
+}
+
+func TestPollingShouldKeepMetricsOfFailedPolls(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := testDevice(s, testPassword)
+	d.PollInterval = 20 * time.Millisecond
+	cfg := &config.Config{Devices: []config.Device{d}}
+	c := newTestCollector(t, cfg, WithPolling(ctx, time.Hour)).(*collector)
+
+	waitForMetric(t, c, "mikrotik_scrape_collector_success{collector=resource}", 1)
+
+	s.Handle("/system/resource/print", routerostest.Reply{Trap: "not enough permissions (9)"})
+	metrics := waitForMetric(t, c, "mikrotik_scrape_collector_success{collector=resource}", 0)
+
+	assert.Equal(t, 7.0, metrics["mikrotik_system_cpu_load{boardname=RB4011,version=6.45.9 (long-term)}"])
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=interface}"])
+	assert.True(t, metrics["mikrotik_poll_staleness_seconds{collector=resource}"] > 0)
+}
+
+func TestPollingShouldStopWhenContextIsDone(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := testDevice(s, testPassword)
+	d.PollInterval = 10 * time.Millisecond
+	cfg := &config.Config{Devices: []config.Device{d}}
+	c := newTestCollector(t, cfg, WithPolling(ctx, time.Hour)).(*collector)
+
+	waitForMetric(t, c, "mikrotik_scrape_collector_success{collector=resource}", 1)
+	cancel()
+
+	time.Sleep(50 * time.Millisecond)
+	commands := len(s.Commands())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, commands, len(s.Commands()))
+}
@@ -25,6 +25,10 @@ func newPoolCollector() routerOSCollector {
 	return c
 }
 
+func (c *poolCollector) name() string {
+	return "pool"
+}
+
 func (c *poolCollector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.usedCountDesc
 }
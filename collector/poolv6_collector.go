@@ -25,6 +25,10 @@ func newPoolV6Collector() routerOSCollector {
 	return c
 }
 
+func (c *poolv6Collector) name() string {
+	return "poolv6"
+}
+
 func (c *poolv6Collector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.usedCountDesc
 }
@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2/proto"
+)
+
+type queueCollector struct {
+	simpleProps  []string
+	treeProps    []string
+	descriptions map[string]*prometheus.Desc
+}
+
+// queueMetric is a statistic of simple and tree queues. Simple queues report
+// it as an upload/download pair.
+type queueMetric struct {
+	property  string
+	name      string
+	help      string
+	valueType prometheus.ValueType
+}
+
+var queueMetrics = []queueMetric{
+	{"bytes", "bytes", "number of bytes passed through the queue", prometheus.CounterValue},
+	{"packets", "packets", "number of packets passed through the queue", prometheus.CounterValue},
+	{"dropped", "dropped_packets", "number of packets dropped by the queue", prometheus.CounterValue},
+	{"queued-packets", "queued_packets", "number of packets currently queued", prometheus.GaugeValue},
+	{"queued-bytes", "queued_bytes", "number of bytes currently queued", prometheus.GaugeValue},
+	{"max-limit", "max_limit_bits_per_second", "configured maximum rate of the queue (0 = unlimited)", prometheus.GaugeValue},
+}
+
+func newQueueCollector() routerOSCollector {
+	c := &queueCollector{}
+	c.init()
+	return c
+}
+
+func (c *queueCollector) init() {
+	c.simpleProps = []string{"name", "parent", "target"}
+	c.treeProps = []string{"name", "parent"}
+	for _, m := range queueMetrics {
+		c.simpleProps = append(c.simpleProps, m.property)
+		c.treeProps = append(c.treeProps, m.property)
+	}
+
+	labelNames := []string{"name", "address", "queue", "parent", "target"}
+	c.descriptions = make(map[string]*prometheus.Desc)
+	for _, m := range queueMetrics {
+		c.descriptions["simple-upload-"+m.property] = description("queue_simple", "upload_"+m.name, m.help+" in upload direction", labelNames)
+		c.descriptions["simple-download-"+m.property] = description("queue_simple", "download_"+m.name, m.help+" in download direction", labelNames)
+		c.descriptions["tree-"+m.property] = description("queue_tree", m.name, m.help, labelNames)
+	}
+}
+
+func (c *queueCollector) name() string {
+	return "queue"
+}
+
+func (c *queueCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descriptions {
+		ch <- d
+	}
+}
+
+func (c *queueCollector) collect(ctx *collectorContext) error {
+	queues, err := c.fetch("/queue/simple", c.simpleProps, ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range queues {
+		c.collectForSimpleQueue(re, ctx)
+	}
+
+	queues, err = c.fetch("/queue/tree", c.treeProps, ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range queues {
+		c.collectForTreeQueue(re, ctx)
+	}
+
+	return nil
+}
+
+func (c *queueCollector) fetch(menu string, props []string, ctx *collectorContext) ([]*proto.Sentence, error) {
+	reply, err := ctx.client.Run(menu+"/print", "?disabled=false", "=.proplist="+strings.Join(props, ","))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": ctx.device.Name,
+			"menu":   menu,
+			"error":  err,
+		}).Error("error fetching queue metrics")
+		return nil, err
+	}
+
+	return reply.Re, nil
+}
+
+func (c *queueCollector) collectForSimpleQueue(re *proto.Sentence, ctx *collectorContext) {
+	labelValues := []string{ctx.device.Name, ctx.device.Address, re.Map["name"], re.Map["parent"], re.Map["target"]}
+
+	for _, m := range queueMetrics {
+		value := re.Map[m.property]
+		if value == "" {
+			continue
+		}
+
+		upload, download, err := splitStringToFloatsWithSeparator(value, "/")
+		if err != nil {
+			c.logParseError(re, m.property, value, err, ctx)
+			continue
+		}
+
+		ctx.ch <- prometheus.MustNewConstMetric(c.descriptions["simple-upload-"+m.property], m.valueType, upload, labelValues...)
+		ctx.ch <- prometheus.MustNewConstMetric(c.descriptions["simple-download-"+m.property], m.valueType, download, labelValues...)
+	}
+}
+
+func (c *queueCollector) collectForTreeQueue(re *proto.Sentence, ctx *collectorContext) {
+	labelValues := []string{ctx.device.Name, ctx.device.Address, re.Map["name"], re.Map["parent"], ""}
+
+	for _, m := range queueMetrics {
+		value := re.Map[m.property]
+		if value == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			c.logParseError(re, m.property, value, err, ctx)
+			continue
+		}
+
+		ctx.ch <- prometheus.MustNewConstMetric(c.descriptions["tree-"+m.property], m.valueType, v, labelValues...)
+	}
+}
+
+func (c *queueCollector) logParseError(re *proto.Sentence, property, value string, err error, ctx *collectorContext) {
+	log.WithFields(log.Fields{
+		"device":   ctx.device.Name,
+		"queue":    re.Map["name"],
+		"property": property,
+		"value":    value,
+		"error":    err,
+	}).Error("error parsing queue metric value")
+	ctx.parseError()
+}
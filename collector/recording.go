@@ -0,0 +1,274 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/routeros.v2"
+	"gopkg.in/routeros.v2/proto"
+)
+
+const redacted = "<redacted>"
+
+// recordingsMu serializes writes to the recording files, as a device may be
+// scraped by /metrics and /probe at the same time
+var recordingsMu sync.Mutex
+
+// recording is a command sent to a device and the reply received, with all
+// sentences stored as the words sent on the wire
+type recording struct {
+	Time    time.Time  `json:"time"`
+	Command []string   `json:"command"`
+	Re      [][]string `json:"re,omitempty"`
+	Done    []string   `json:"done,omitempty"`
+	Trap    []string   `json:"trap,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// recordingFile returns the file the recordings of a device are stored in
+func recordingFile(dir, device string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(device)
+	return filepath.Join(dir, name+".jsonl")
+}
+
+// recordingClient writes every command and its reply to the recording file of
+// the device before returning the reply
+type recordingClient struct {
+	client commandRunner
+	file   string
+	device string
+}
+
+func newRecordingClient(client commandRunner, dir, device string) *recordingClient {
+	return &recordingClient{
+		client: client,
+		file:   recordingFile(dir, device),
+		device: device,
+	}
+}
+
+func (c *recordingClient) Run(sentence ...string) (*routeros.Reply, error) {
+	reply, err := c.client.Run(sentence...)
+
+	r := newRecording(sentence, reply, err)
+	if werr := appendRecording(c.file, r); werr != nil {
+		log.WithFields(log.Fields{
+			"device": c.device,
+			"file":   c.file,
+			"error":  werr,
+		}).Error("error writing recording")
+	}
+
+	return reply, err
+}
+
+func newRecording(sentence []string, reply *routeros.Reply, err error) *recording {
+	r := &recording{
+		Time:    time.Now(),
+		Command: redactCommand(sentence),
+	}
+
+	if reply != nil {
+		for _, re := range reply.Re {
+			r.Re = append(r.Re, sentenceWords(re))
+		}
+
+		if reply.Done != nil {
+			r.Done = sentenceWords(reply.Done)
+		}
+	}
+
+	if devErr, ok := err.(*routeros.DeviceError); ok {
+		r.Trap = sentenceWords(devErr.Sentence)
+	} else if err != nil {
+		r.Error = err.Error()
+	}
+
+	return r
+}
+
+func appendRecording(file string, r *recording) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(r)
+	if err != nil {
+		return err
+	}
+
+	recordingsMu.Lock()
+	defer recordingsMu.Unlock()
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(buf.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// sentenceWords converts a sentence back to its words, redacting secrets
+func sentenceWords(sen *proto.Sentence) []string {
+	words := []string{sen.Word}
+	for _, p := range sen.List {
+		value := p.Value
+		if isSecret(p.Key) {
+			value = redacted
+		}
+
+		words = append(words, "="+p.Key+"="+value)
+	}
+
+	return words
+}
+
+func redactCommand(sentence []string) []string {
+	words := make([]string, len(sentence))
+	for i, w := range sentence {
+		words[i] = w
+
+		kv := strings.SplitN(w, "=", 3)
+		if len(kv) == 3 && kv[0] == "" && isSecret(kv[1]) {
+			words[i] = "=" + kv[1] + "=" + redacted
+		}
+	}
+
+	return words
+}
+
+// isSecret returns whether a property may contain a password or key, e.g. of
+// PPP secrets or IPsec peers read by custom collectors
+func isSecret(key string) bool {
+	for _, s := range []string{"password", "secret", "private-key", "pre-shared-key", "passphrase"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replayClient answers commands from the recordings of a device instead of
+// sending them to the device. Commands recorded more than once are answered
+// in order, the last reply is repeated.
+type replayClient struct {
+	mu         sync.Mutex
+	recordings map[string][]*recording
+}
+
+func loadReplayClient(dir, device string) (*replayClient, error) {
+	f, err := os.Open(recordingFile(dir, device))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &replayClient{recordings: make(map[string][]*recording)}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		r := &recording{}
+		err := json.Unmarshal(scanner.Bytes(), r)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse recording in line %d: %v", line, err)
+		}
+
+		key := commandKey(r.Command)
+		c.recordings[key] = append(c.recordings[key], r)
+	}
+
+	return c, scanner.Err()
+}
+
+func (c *replayClient) Run(sentence ...string) (*routeros.Reply, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := commandKey(sentence)
+	recordings := c.recordings[key]
+	if len(recordings) == 0 {
+		return nil, fmt.Errorf("no recording for command %s", strings.Join(sentence, " "))
+	}
+
+	r := recordings[0]
+	if len(recordings) > 1 {
+		c.recordings[key] = recordings[1:]
+	}
+
+	return r.reply()
+}
+
+func (r *recording) reply() (*routeros.Reply, error) {
+	if r.Trap != nil {
+		return nil, &routeros.DeviceError{Sentence: wordsSentence(r.Trap)}
+	}
+
+	if r.Error != "" {
+		return nil, errors.New(r.Error)
+	}
+
+	reply := &routeros.Reply{}
+	for _, re := range r.Re {
+		reply.Re = append(reply.Re, wordsSentence(re))
+	}
+
+	if r.Done != nil {
+		reply.Done = wordsSentence(r.Done)
+	}
+
+	return reply, nil
+}
+
+// commandKey identifies a command by its words, ignoring the .proplist
+// attribute, so recordings can still be replayed after properties were added
+// to a collector
+func commandKey(sentence []string) string {
+	words := make([]string, 0, len(sentence))
+	for _, w := range sentence {
+		if !strings.HasPrefix(w, "=.proplist=") {
+			words = append(words, w)
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+func wordsSentence(words []string) *proto.Sentence {
+	sen := proto.NewSentence()
+	if len(words) == 0 {
+		return sen
+	}
+
+	sen.Word = words[0]
+	for _, w := range words[1:] {
+		kv := strings.SplitN(strings.TrimPrefix(w, "="), "=", 2)
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+
+		p := proto.Pair{Key: kv[0], Value: kv[1]}
+		sen.List = append(sen.List, p)
+		sen.Map[p.Key] = p.Value
+	}
+
+	return sen
+}
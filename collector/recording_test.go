@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"mikrotik-exporter/collector/routerostest"
+	"mikrotik-exporter/config"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recordings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := newTestServer(t)
+	s.Handle("/ppp/secret/print", routerostest.Reply{Re: re{
+		{"name": "branch", "password": "hunter2", "routes": "3"},
+	}})
+	s.Handle("/routing/bgp/peer/print", routerostest.Reply{Trap: "no such command prefix"})
+
+	cfg := &config.Config{
+		Devices: []config.Device{testDevice(s, testPassword)},
+		CustomCollectors: []config.CustomCollector{{
+			Name:    "ppp_secret",
+			Command: "/ppp/secret/print",
+			Labels:  []config.CustomLabel{{Property: "name", Label: "secret"}, {Property: "password"}},
+			Metrics: []config.CustomMetric{{Property: "routes"}},
+		}},
+	}
+	recorded := collectMetrics(t, newTestCollector(t, cfg, WithBGP(), WithRecordDir(dir)))
+	s.Close()
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "router.jsonl"))
+	if err != nil {
+		t.Fatalf("could not read recording: %v", err)
+	}
+	assert.NotContains(t, string(b), "hunter2")
+	assert.Contains(t, string(b), "=password=<redacted>")
+
+	replayed := collectMetrics(t, newTestCollector(t, cfg, WithBGP(), WithReplayDir(dir)))
+
+	for name, v := range recorded {
+		if strings.HasPrefix(name, "mikrotik_connection_") || strings.HasPrefix(name, "mikrotik_device_") || strings.HasPrefix(name, "mikrotik_scrape_collector_duration_seconds") {
+			continue
+		}
+
+		if strings.HasPrefix(name, "mikrotik_ppp_secret_routes") {
+			name = strings.Replace(name, "password=hunter2", "password="+redacted, 1)
+		}
+
+		assert.Equal(t, v, replayed[name], name)
+	}
+	assert.Equal(t, 0.0, replayed["mikrotik_scrape_collector_success{collector=bgp}"])
+}
+
+func TestReplayRecording(t *testing.T) {
+	cfg := &config.Config{
+		Devices: []config.Device{{Name: "router", Address: "10.0.0.1"}},
+	}
+	metrics := collectMetrics(t, newTestCollector(t, cfg, WithBGP(), WithReplayDir("testdata/recordings")))
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=interface}"])
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+	assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=bgp}"])
+
+	resourceLabels := "{boardname=RB4011iGS+,version=6.48.6 (long-term)}"
+	assert.Equal(t, 1483506.0, metrics["mikrotik_system_uptime"+resourceLabels])
+	assert.Equal(t, 3.0, metrics["mikrotik_system_cpu_load"+resourceLabels])
+
+	ether1Labels := "{comment=uplink,interface=ether1,mac_address=4C:5E:0C:00:00:01,type=ether}"
+	assert.Equal(t, 1482300.0, metrics["mikrotik_interface_last_link_up_time"+ether1Labels])
+	assert.Equal(t, 90842347134.0, metrics["mikrotik_interface_rx_byte"+ether1Labels])
+	assert.Equal(t, 0.0, metrics["mikrotik_interface_running{comment=,interface=sfp-sfpplus1,mac_address=4C:5E:0C:00:00:02,type=ether}"])
+}
+
+func TestReplayShouldFailWithoutRecording(t *testing.T) {
+	cfg := &config.Config{
+		Devices: []config.Device{{Name: "missing", Address: "10.0.0.1"}},
+	}
+	metrics := collectMetrics(t, newTestCollector(t, cfg, WithReplayDir("testdata/recordings")))
+
+	assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=interface}"])
+	assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+}
@@ -29,6 +29,10 @@ func (c *resourceCollector) init() {
 	}
 }
 
+func (c *resourceCollector) name() string {
+	return "resource"
+}
+
 func (c *resourceCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -91,6 +95,7 @@ func (c *resourceCollector) collectMetricForProperty(property string, valueType
 			"value":    re.Map[property],
 			"error":    err,
 		}).Error("error parsing system resource metric value")
+		ctx.parseError()
 		return
 	}
 
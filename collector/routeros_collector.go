@@ -5,6 +5,7 @@ import (
 )
 
 type routerOSCollector interface {
+	name() string
 	describe(ch chan<- *prometheus.Desc)
 	collect(ctx *collectorContext) error
 }
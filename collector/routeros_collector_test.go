@@ -0,0 +1,701 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"mikrotik-exporter/collector/routerostest"
+	"mikrotik-exporter/config"
+)
+
+type re = []map[string]string
+
+func TestCollectors(t *testing.T) {
+	testCases := []struct {
+		collector string
+		option    Option
+		replies   map[string]routerostest.Reply
+		expected  map[string]float64
+	}{
+		{
+			collector: "bgp",
+			option:    WithBGP(),
+			replies: map[string]routerostest.Reply{
+				"/routing/bgp/peer/print": {Re: re{{
+					"name":             "upstream",
+					"remote-as":        "65001",
+					"state":            "established",
+					"prefix-count":     "800000",
+					"updates-received": "42",
+				}}},
+			},
+			expected: map[string]float64{
+				"mikrotik_bgp_up{asn=65001,session=upstream}":               1,
+				"mikrotik_bgp_prefix_count{asn=65001,session=upstream}":     800000,
+				"mikrotik_bgp_updates_received{asn=65001,session=upstream}": 42,
+				"mikrotik_bgp_withdrawn_sent{asn=65001,session=upstream}":   0,
+			},
+		},
+		{
+			collector: "bgp",
+			option:    WithBGP(),
+			replies: map[string]routerostest.Reply{
+				"/system/resource/print": {Re: re{{"version": "7.12.1 (stable)"}}},
+				"/routing/bgp/session/print": {Re: re{
+					{
+						"name":           "upstream-1",
+						"remote.address": "192.0.2.1",
+						"remote.as":      "65001",
+						"local.role":     "ebgp",
+						"established":    "true",
+						"uptime":         "1h2m3s450ms",
+						"prefix-count":   "800000",
+					},
+					{
+						"name":           "backup-1",
+						"remote.address": "2001:db8::1",
+						"remote.as":      "65002",
+						"local.role":     "ebgp",
+					},
+				}},
+				"/routing/route/print ?afi=ip ?received-from=upstream-1 =count-only=":       {Done: map[string]string{"ret": "790000"}},
+				"/routing/route/print ?afi=ipv6 ?received-from=upstream-1 =count-only=":     {Done: map[string]string{"ret": "10000"}},
+				"/routing/bgp/advertisements/print ?afi=ip ?peer=upstream-1 =count-only=":   {Done: map[string]string{"ret": "2"}},
+				"/routing/bgp/advertisements/print ?afi=ipv6 ?peer=upstream-1 =count-only=": {Done: map[string]string{"ret": "1"}},
+			},
+			expected: map[string]float64{
+				"mikrotik_bgp_up{asn=65001,session=upstream-1}":                                                    1,
+				"mikrotik_bgp_up{asn=65002,session=backup-1}":                                                      0,
+				"mikrotik_bgp_prefix_count{asn=65001,session=upstream-1}":                                          800000,
+				"mikrotik_bgp_uptime_seconds{asn=65001,session=upstream-1}":                                        3723.45,
+				"mikrotik_bgp_session_info{asn=65001,local_role=ebgp,remote_address=192.0.2.1,session=upstream-1}": 1,
+				"mikrotik_bgp_prefixes_received{afi=ipv4,asn=65001,session=upstream-1}":                            790000,
+				"mikrotik_bgp_prefixes_received{afi=ipv6,asn=65001,session=upstream-1}":                            10000,
+				"mikrotik_bgp_prefixes_received{afi=ipv4,asn=65002,session=backup-1}":                              0,
+				"mikrotik_bgp_prefixes_advertised{afi=ipv4,asn=65002,session=backup-1}":                            0,
+				"mikrotik_bgp_prefixes_advertised{afi=ipv4,asn=65001,session=upstream-1}":                          2,
+				"mikrotik_bgp_prefixes_advertised{afi=ipv6,asn=65001,session=upstream-1}":                          1,
+			},
+		},
+		{
+			collector: "routes",
+			option:    WithRoutes(),
+			replies: map[string]routerostest.Reply{
+				"/ip/route/print ?disabled=false =count-only=":      {Done: map[string]string{"ret": "15"}},
+				"/ip/route/print ?disabled=false ?bgp =count-only=": {Done: map[string]string{"ret": "10"}},
+				"/ip/route/print": {Done: map[string]string{"ret": "1"}},
+			},
+			expected: map[string]float64{
+				"mikrotik_routes_ipv4_total_count{}":                    15,
+				"mikrotik_routes_ipv4_protocol_count{protocol=bgp}":     10,
+				"mikrotik_routes_ipv4_protocol_count{protocol=connect}": 1,
+				"mikrotik_routes_ipv4_protocol_count{protocol=static}":  1,
+				"mikrotik_routes_ipv4_protocol_count{protocol=ospf}":    1,
+				"mikrotik_routes_ipv4_protocol_count{protocol=dynamic}": 1,
+			},
+		},
+		{
+			collector: "routes",
+			option:    WithRoutes(),
+			replies: map[string]routerostest.Reply{
+				"/system/resource/print":                                     {Re: re{{"version": "7.12.1 (stable)"}}},
+				"/ip/route/print ?disabled=false =count-only=":               {Done: map[string]string{"ret": "15"}},
+				"/ip/route/print ?disabled=false ?bgp=true =count-only=":     {Done: map[string]string{"ret": "10"}},
+				"/ip/route/print ?disabled=false ?static=true =count-only=":  {Done: map[string]string{"ret": "2"}},
+				"/ip/route/print ?disabled=false ?ospf=true =count-only=":    {Done: map[string]string{"ret": "0"}},
+				"/ip/route/print ?disabled=false ?dynamic=true =count-only=": {Done: map[string]string{"ret": "13"}},
+				"/ip/route/print ?disabled=false ?connect=true =count-only=": {Done: map[string]string{"ret": "3"}},
+			},
+			expected: map[string]float64{
+				"mikrotik_routes_ipv4_total_count{}":                    15,
+				"mikrotik_routes_ipv4_protocol_count{protocol=bgp}":     10,
+				"mikrotik_routes_ipv4_protocol_count{protocol=static}":  2,
+				"mikrotik_routes_ipv4_protocol_count{protocol=connect}": 3,
+			},
+		},
+		{
+			collector: "routesv6",
+			option:    WithRoutesV6(),
+			replies: map[string]routerostest.Reply{
+				"/ipv6/route/print ?disabled=false =count-only=":         {Done: map[string]string{"ret": "5"}},
+				"/ipv6/route/print ?disabled=false ?static =count-only=": {Done: map[string]string{"ret": "2"}},
+				"/ipv6/route/print": {Done: map[string]string{"ret": "0"}},
+			},
+			expected: map[string]float64{
+				"mikrotik_routes_ipv6_total_count{}":                   5,
+				"mikrotik_routes_ipv6_protocol_count{protocol=static}": 2,
+				"mikrotik_routes_ipv6_protocol_count{protocol=bgp}":    0,
+			},
+		},
+		{
+			collector: "routesv6",
+			option:    WithRoutesV6(),
+			replies: map[string]routerostest.Reply{
+				"/system/resource/print":                                       {Re: re{{"version": "7.12.1 (stable)"}}},
+				"/ipv6/route/print ?disabled=false =count-only=":               {Done: map[string]string{"ret": "5"}},
+				"/ipv6/route/print ?disabled=false ?bgp=true =count-only=":     {Done: map[string]string{"ret": "0"}},
+				"/ipv6/route/print ?disabled=false ?static=true =count-only=":  {Done: map[string]string{"ret": "2"}},
+				"/ipv6/route/print ?disabled=false ?ospf=true =count-only=":    {Done: map[string]string{"ret": "0"}},
+				"/ipv6/route/print ?disabled=false ?dynamic=true =count-only=": {Done: map[string]string{"ret": "3"}},
+				"/ipv6/route/print ?disabled=false ?connect=true =count-only=": {Done: map[string]string{"ret": "3"}},
+			},
+			expected: map[string]float64{
+				"mikrotik_routes_ipv6_total_count{}":                   5,
+				"mikrotik_routes_ipv6_protocol_count{protocol=static}": 2,
+				"mikrotik_routes_ipv6_protocol_count{protocol=bgp}":    0,
+			},
+		},
+		{
+			collector: "dhcp",
+			option:    WithDHCP(),
+			replies: map[string]routerostest.Reply{
+				"/ip/dhcp-server/print": {Re: re{{"name": "lan"}}},
+				"/ip/dhcp-server/lease/print ?server=lan =active= =count-only=": {Done: map[string]string{"ret": "23"}},
+			},
+			expected: map[string]float64{
+				"mikrotik_dhcp_leases_active_count{server=lan}": 23,
+			},
+		},
+		{
+			collector: "dhcp-leases",
+			option:    WithDHCPL(),
+			replies: map[string]routerostest.Reply{
+				"/ip/dhcp-server/lease/print ?status=bound": {Re: re{{
+					"active-mac-address": "00:11:22:33:44:55",
+					"server":             "lan",
+					"status":             "bound",
+					"expires-after":      "5m",
+					"active-address":     "192.168.88.10",
+					"host-name":          "laptop",
+				}}},
+			},
+			expected: map[string]float64{
+				"mikrotik_dhcp_leases_info{active_address=192.168.88.10,active_mac_address=00:11:22:33:44:55,expires_after=300,hostname=laptop,server=lan,status=bound}": 1,
+			},
+		},
+		{
+			collector: "dhcpv6",
+			option:    WithDHCPv6(),
+			replies: map[string]routerostest.Reply{
+				"/ipv6/dhcp-server/print":                                 {Re: re{{"name": "pd"}}},
+				"/ipv6/dhcp-server/binding/print ?server=pd =count-only=": {Done: map[string]string{"ret": "4"}},
+			},
+			expected: map[string]float64{
+				"mikrotik_dhcpv6_binding_count{server=pd}": 4,
+			},
+		},
+		{
+			collector: "pool",
+			option:    WithPool(),
+			replies: map[string]routerostest.Reply{
+				"/ip/pool/print": {Re: re{{"name": "dhcp"}}},
+				"/ip/pool/used/print ?pool=dhcp =count-only=": {Done: map[string]string{"ret": "12"}},
+			},
+			expected: map[string]float64{
+				"mikrotik_ip_pool_ipv4_used_count{pool=dhcp}": 12,
+			},
+		},
+		{
+			collector: "poolv6",
+			option:    WithPoolV6(),
+			replies: map[string]routerostest.Reply{
+				"/ipv6/pool/print": {Re: re{{"name": "pd"}}},
+				"/ipv6/pool/used/print ?pool=pd =count-only=": {Done: map[string]string{"ret": "3"}},
+			},
+			expected: map[string]float64{
+				"mikrotik_ip_pool_ipv6_used_count{pool=pd}": 3,
+			},
+		},
+		{
+			collector: "optics",
+			option:    WithOptics(),
+			replies: map[string]routerostest.Reply{
+				"/interface/ethernet/print": {Re: re{{"name": "ether1"}, {"name": "sfp1"}}},
+				"/interface/ethernet/monitor =numbers=sfp1 =once=": {Re: re{{
+					"name":            "sfp1",
+					"sfp-rx-loss":     "false",
+					"sfp-tx-fault":    "true",
+					"sfp-temperature": "41",
+					"sfp-rx-power":    "-7.5",
+				}}},
+			},
+			expected: map[string]float64{
+				"mikrotik_optics_rx_status{interface=sfp1}":           1,
+				"mikrotik_optics_tx_status{interface=sfp1}":           0,
+				"mikrotik_optics_temperature_celsius{interface=sfp1}": 41,
+				"mikrotik_optics_rx_power_dbm{interface=sfp1}":        -7.5,
+			},
+		},
+		{
+			collector: "wlan-stations",
+			option:    WithWlanSTA(),
+			replies: map[string]routerostest.Reply{
+				"/interface/wireless/registration-table/print": {Re: re{{
+					"interface":           "wlan1",
+					"mac-address":         "AA:BB:CC:DD:EE:FF",
+					"uptime":              "1h",
+					"signal-to-noise":     "40",
+					"signal-strength-ch0": "-60",
+					"signal-strength-ch1": "-62",
+					"tx-ccq":              "90",
+					"rx-rate":             "144.4Mbps-20MHz/2S/SGI",
+					"tx-rate":             "130Mbps-20MHz/2S",
+					"packets":             "100,200",
+					"bytes":               "1000,2000",
+					"frames":              "10,20",
+				}}},
+			},
+			expected: map[string]float64{
+				"mikrotik_wlan_station_uptime{interface=wlan1,mac_address=AA:BB:CC:DD:EE:FF}":   3600,
+				"mikrotik_wlan_station_rx_rate{interface=wlan1,mac_address=AA:BB:CC:DD:EE:FF}":  144.4,
+				"mikrotik_wlan_station_tx_bytes{interface=wlan1,mac_address=AA:BB:CC:DD:EE:FF}": 1000,
+				"mikrotik_wlan_station_rx_bytes{interface=wlan1,mac_address=AA:BB:CC:DD:EE:FF}": 2000,
+			},
+		},
+		{
+			collector: "wlan-interfaces",
+			option:    WithWlanIF(),
+			replies: map[string]routerostest.Reply{
+				"/interface/wireless/print ?disabled=false": {Re: re{{"name": "wlan1"}}},
+				"/interface/wireless/monitor =numbers=wlan1 =once=": {Re: re{{
+					"channel":            "2412/20-Ce/gn",
+					"registered-clients": "3",
+					"noise-floor":        "-105",
+					"overall-tx-ccq":     "85",
+				}}},
+			},
+			expected: map[string]float64{
+				"mikrotik_wlan_interface_registered_clients{channel=2412/20-Ce/gn,interface=wlan1}": 3,
+				"mikrotik_wlan_interface_noise_floor{channel=2412/20-Ce/gn,interface=wlan1}":        -105,
+			},
+		},
+		{
+			collector: "monitor",
+			option:    WithMonitor(),
+			replies: map[string]routerostest.Reply{
+				"/interface/ethernet/print": {Re: re{{"name": "ether1"}}},
+				"/interface/ethernet/monitor =numbers=ether1 =once=": {Re: re{{
+					"name":        "ether1",
+					"status":      "link-ok",
+					"rate":        "1Gbps",
+					"full-duplex": "true",
+				}}},
+			},
+			expected: map[string]float64{
+				"mikrotik_monitor_status{interface=ether1}":      1,
+				"mikrotik_monitor_rate{interface=ether1}":        1000,
+				"mikrotik_monitor_full_duplex{interface=ether1}": 1,
+			},
+		},
+		{
+			collector: "ipsec-peers",
+			option:    WithIPSecPeers(),
+			replies: map[string]routerostest.Reply{
+				"/ip/ipsec/active-peers/print": {Re: re{{
+					"id":             "office",
+					"remote-address": "203.0.113.1",
+					"state":          "established",
+					"uptime":         "2m",
+					"rx-bytes":       "4096",
+				}}},
+			},
+			expected: map[string]float64{
+				"mikrotik_ipsec_peers_uptime{id=office,remote_address=203.0.113.1,state=established}":   120,
+				"mikrotik_ipsec_peers_rx_bytes{id=office,remote_address=203.0.113.1,state=established}": 4096,
+			},
+		},
+		{
+			collector: "ospf-neighbor",
+			option:    WithOSPFNeighbor(),
+			replies: map[string]routerostest.Reply{
+				"/routing/ospf/neighbor/print": {Re: re{{
+					"instance":          "default",
+					"router-id":         "10.0.0.2",
+					"address":           "10.0.0.2",
+					"interface":         "ether2",
+					"priority":          "1",
+					"dr-address":        "10.0.0.2",
+					"backup-dr-address": "10.0.0.1",
+					"state":             "Full",
+					"state-changes":     "6",
+				}}},
+				"/routing/ospf/interface/print": {Re: re{{
+					"interface": "ether2",
+					"area":      "backbone",
+					"state":     "backup",
+					"cost":      "10",
+					"priority":  "1",
+				}}},
+				"/routing/ospf/lsa/print": {Re: re{
+					{"instance": "default", "area": "backbone", "type": "router"},
+					{"instance": "default", "area": "backbone", "type": "router"},
+					{"instance": "default", "area": "backbone", "type": "network"},
+				}},
+				"/routing/ospf-v3/neighbor/print": {Re: re{{
+					"instance":  "default",
+					"router-id": "10.0.0.3",
+					"address":   "fe80::3",
+					"interface": "ether3",
+					"state":     "2-Way",
+				}}},
+				"/routing/ospf-v3/interface/print": {},
+				"/routing/ospf-v3/lsa/print":       {},
+			},
+			expected: map[string]float64{
+				"mikrotik_ospf_neighbor_state{area=,instance=default,interface=ether2,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}":         8,
+				"mikrotik_ospf_neighbor_is_full{area=,instance=default,interface=ether2,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}":       1,
+				"mikrotik_ospf_neighbor_state_changes{area=,instance=default,interface=ether2,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}": 6,
+				"mikrotik_ospf_neighbor_priority{area=,instance=default,interface=ether2,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}":      1,
+				"mikrotik_ospf_neighbor_is_dr{area=,instance=default,interface=ether2,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}":         1,
+				"mikrotik_ospf_neighbor_is_bdr{area=,instance=default,interface=ether2,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}":        0,
+				"mikrotik_ospf_neighbor_state{area=,instance=default,interface=ether3,neighbor_address=fe80::3,ospf_version=3,router_id=10.0.0.3}":          4,
+				"mikrotik_ospf_neighbor_is_full{area=,instance=default,interface=ether3,neighbor_address=fe80::3,ospf_version=3,router_id=10.0.0.3}":        0,
+				"mikrotik_ospf_interface_state{area=backbone,instance=,interface=ether2,ospf_version=2}":                                                    6,
+				"mikrotik_ospf_interface_cost{area=backbone,instance=,interface=ether2,ospf_version=2}":                                                     10,
+				"mikrotik_ospf_lsa_count{area=backbone,instance=default,ospf_version=2,type=router}":                                                        2,
+				"mikrotik_ospf_lsa_count{area=backbone,instance=default,ospf_version=2,type=network}":                                                       1,
+			},
+		},
+		{
+			collector: "ospf-neighbor",
+			option:    WithOSPFNeighbor(),
+			replies: map[string]routerostest.Reply{
+				"/system/resource/print": {Re: re{{"version": "7.12.1 (stable)"}}},
+				"/routing/ospf/instance/print": {Re: re{
+					{"name": "default-v2", "version": "2"},
+					{"name": "default-v3", "version": "3"},
+				}},
+				"/routing/ospf/neighbor/print": {Re: re{
+					{
+						"instance":  "default-v2",
+						"area":      "backbone-v2",
+						"router-id": "10.0.0.2",
+						"address":   "10.0.0.2",
+						"priority":  "128",
+						"dr":        "10.0.0.1",
+						"bdr":       "10.0.0.2",
+						"state":     "Full",
+						"timeout":   "38s",
+					},
+					{
+						"instance":  "default-v3",
+						"area":      "backbone-v3",
+						"router-id": "10.0.0.2",
+						"address":   "fe80::2",
+						"state":     "Init",
+					},
+				}},
+				"/routing/ospf/interface/print": {Re: re{{
+					"instance":  "default-v2",
+					"area":      "backbone-v2",
+					"interface": "ether2",
+					"state":     "dr",
+					"cost":      "1",
+					"priority":  "128",
+				}}},
+				"/routing/ospf/lsa/print": {Re: re{
+					{"instance": "default-v2", "area": "backbone-v2", "type": "router"},
+					{"instance": "default-v3", "area": "backbone-v3", "type": "router"},
+				}},
+			},
+			expected: map[string]float64{
+				"mikrotik_ospf_neighbor_state{area=backbone-v2,instance=default-v2,interface=,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}":              8,
+				"mikrotik_ospf_neighbor_is_bdr{area=backbone-v2,instance=default-v2,interface=,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}":             1,
+				"mikrotik_ospf_neighbor_is_dr{area=backbone-v2,instance=default-v2,interface=,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}":              0,
+				"mikrotik_ospf_neighbor_dead_timer_seconds{area=backbone-v2,instance=default-v2,interface=,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}": 38,
+				"mikrotik_ospf_neighbor_state{area=backbone-v3,instance=default-v3,interface=,neighbor_address=fe80::2,ospf_version=3,router_id=10.0.0.2}":               3,
+				"mikrotik_ospf_interface_state{area=backbone-v2,instance=default-v2,interface=ether2,ospf_version=2}":                                                    5,
+				"mikrotik_ospf_lsa_count{area=backbone-v3,instance=default-v3,ospf_version=3,type=router}":                                                               1,
+			},
+		},
+		{
+			collector: "firewall",
+			option:    WithFirewall(false),
+			replies: map[string]routerostest.Reply{
+				"/ip/firewall/filter/print ?disabled=false": {Re: re{
+					{"chain": "input", "action": "accept", "comment": "established", "bytes": "1000", "packets": "10"},
+					{"chain": "input", "action": "drop", "bytes": "200", "packets": "4"},
+					{"chain": "input", "action": "drop", "bytes": "300", "packets": "6"},
+				}},
+				"/ip/firewall/nat/print ?disabled=false": {Re: re{
+					{"chain": "srcnat", "action": "masquerade", "comment": "wan", "bytes": "5000", "packets": "50"},
+				}},
+				"/ip/firewall/mangle/print ?disabled=false": {},
+				"/ip/firewall/raw/print ?disabled=false":    {},
+			},
+			expected: map[string]float64{
+				"mikrotik_firewall_rule_bytes{action=accept,chain=input,comment=established,ip_version=4,table=filter}":   1000,
+				"mikrotik_firewall_rule_packets{action=accept,chain=input,comment=established,ip_version=4,table=filter}": 10,
+				"mikrotik_firewall_rule_bytes{action=drop,chain=input,comment=,ip_version=4,table=filter}":                500,
+				"mikrotik_firewall_rule_packets{action=drop,chain=input,comment=,ip_version=4,table=filter}":              10,
+				"mikrotik_firewall_rule_bytes{action=masquerade,chain=srcnat,comment=wan,ip_version=4,table=nat}":         5000,
+			},
+		},
+		{
+			collector: "firewallv6",
+			option:    WithFirewallV6(true),
+			replies: map[string]routerostest.Reply{
+				"/system/resource/print": {Re: re{{"version": "7.12.1 (stable)"}}},
+				"/ipv6/firewall/filter/print ?disabled=false": {Re: re{
+					{"chain": "forward", "action": "drop", "comment": "invalid", "bytes": "64", "packets": "1"},
+					{"chain": "forward", "action": "accept", "bytes": "1000", "packets": "10"},
+				}},
+				"/ipv6/firewall/nat/print ?disabled=false": {Re: re{
+					{"chain": "srcnat", "action": "masquerade", "comment": "nat66", "bytes": "128", "packets": "2"},
+				}},
+				"/ipv6/firewall/mangle/print ?disabled=false": {},
+				"/ipv6/firewall/raw/print ?disabled=false":    {},
+			},
+			expected: map[string]float64{
+				"mikrotik_firewall_rule_bytes{action=drop,chain=forward,comment=invalid,ip_version=6,table=filter}":   64,
+				"mikrotik_firewall_rule_packets{action=masquerade,chain=srcnat,comment=nat66,ip_version=6,table=nat}": 2,
+			},
+		},
+		{
+			collector: "conntrack",
+			option:    WithConntrack(2),
+			replies: map[string]routerostest.Reply{
+				"/ip/firewall/connection/tracking/print": {Re: re{{
+					"enabled":       "auto",
+					"max-entries":   "1048576",
+					"total-entries": "5",
+				}}},
+				"/ip/firewall/connection/print ?protocol=tcp =count-only=":                        {Done: map[string]string{"ret": "3"}},
+				"/ip/firewall/connection/print ?protocol=udp =count-only=":                        {Done: map[string]string{"ret": "2"}},
+				"/ip/firewall/connection/print ?protocol=tcp ?tcp-state=established =count-only=": {Done: map[string]string{"ret": "2"}},
+				"/ip/firewall/connection/print ?protocol=tcp ?tcp-state=time-wait =count-only=":   {Done: map[string]string{"ret": "1"}},
+				"/ip/firewall/connection/print": {
+					Re: re{
+						{"src-address": "10.0.0.5:51234"},
+						{"src-address": "10.0.0.5:51235"},
+						{"src-address": "10.0.0.5:53"},
+						{"src-address": "10.0.0.7:40000"},
+						{"src-address": "10.0.0.9:40000"},
+					},
+					Done: map[string]string{"ret": "0"},
+				},
+			},
+			expected: map[string]float64{
+				"mikrotik_conntrack_enabled{}":                                     2,
+				"mikrotik_conntrack_max_entries{}":                                 1048576,
+				"mikrotik_conntrack_entries{}":                                     5,
+				"mikrotik_conntrack_connections{protocol=tcp}":                     3,
+				"mikrotik_conntrack_connections{protocol=udp}":                     2,
+				"mikrotik_conntrack_tcp_connections{state=established}":            2,
+				"mikrotik_conntrack_tcp_connections{state=time-wait}":              1,
+				"mikrotik_conntrack_src_address_connections{src_address=10.0.0.5}": 3,
+				"mikrotik_conntrack_src_address_connections{src_address=10.0.0.7}": 1,
+			},
+		},
+		{
+			collector: "health",
+			option:    WithHealth(),
+			replies: map[string]routerostest.Reply{
+				"/system/health/print": {Re: re{{
+					"voltage":            "24.1",
+					"current":            "186",
+					"temperature":        "38",
+					"cpu-temperature":    "52",
+					"power-consumption":  "4.5",
+					"fan1-speed":         "3600",
+					"psu1-state":         "ok",
+					"psu2-state":         "fail",
+					"fan-mode":           "auto",
+					"board-temperature1": "41",
+				}}},
+			},
+			expected: map[string]float64{
+				"mikrotik_health_voltage_volts{sensor=voltage}":                  24.1,
+				"mikrotik_health_current_amperes{sensor=current}":                0.186,
+				"mikrotik_health_temperature_celsius{sensor=temperature}":        38,
+				"mikrotik_health_temperature_celsius{sensor=cpu-temperature}":    52,
+				"mikrotik_health_temperature_celsius{sensor=board-temperature1}": 41,
+				"mikrotik_health_power_watts{sensor=power-consumption}":          4.5,
+				"mikrotik_health_fan_speed_rpm{sensor=fan1-speed}":               3600,
+				"mikrotik_health_psu_ok{sensor=psu1-state}":                      1,
+				"mikrotik_health_psu_ok{sensor=psu2-state}":                      0,
+			},
+		},
+		{
+			collector: "health",
+			option:    WithHealth(),
+			replies: map[string]routerostest.Reply{
+				"/system/resource/print": {Re: re{{"version": "7.12.1 (stable)"}}},
+				"/system/health/print": {Re: re{
+					{"name": "voltage", "value": "24.1", "type": "V"},
+					{"name": "cpu-temperature", "value": "52", "type": "C"},
+					{"name": "fan1-speed", "value": "3600", "type": "RPM"},
+					{"name": "fan-state", "value": "fail", "type": ""},
+					{"name": "psu1-state", "value": "ok", "type": ""},
+				}},
+			},
+			expected: map[string]float64{
+				"mikrotik_health_voltage_volts{sensor=voltage}":               24.1,
+				"mikrotik_health_temperature_celsius{sensor=cpu-temperature}": 52,
+				"mikrotik_health_fan_speed_rpm{sensor=fan1-speed}":            3600,
+				"mikrotik_health_fan_ok{sensor=fan-state}":                    0,
+				"mikrotik_health_psu_ok{sensor=psu1-state}":                   1,
+			},
+		},
+		{
+			collector: "queue",
+			option:    WithQueue(),
+			replies: map[string]routerostest.Reply{
+				"/queue/simple/print ?disabled=false": {Re: re{{
+					"name":           "customer-1",
+					"parent":         "none",
+					"target":         "192.168.88.10/32",
+					"bytes":          "1000/20000",
+					"packets":        "10/200",
+					"dropped":        "0/3",
+					"queued-packets": "0/1",
+					"queued-bytes":   "0/1500",
+					"max-limit":      "10000000/50000000",
+				}}},
+				"/queue/tree/print ?disabled=false": {Re: re{{
+					"name":           "download",
+					"parent":         "global",
+					"bytes":          "30000",
+					"packets":        "300",
+					"dropped":        "5",
+					"queued-packets": "2",
+					"queued-bytes":   "3000",
+					"max-limit":      "0",
+				}}},
+			},
+			expected: map[string]float64{
+				"mikrotik_queue_simple_upload_bytes{parent=none,queue=customer-1,target=192.168.88.10/32}":                       1000,
+				"mikrotik_queue_simple_download_bytes{parent=none,queue=customer-1,target=192.168.88.10/32}":                     20000,
+				"mikrotik_queue_simple_download_dropped_packets{parent=none,queue=customer-1,target=192.168.88.10/32}":           3,
+				"mikrotik_queue_simple_download_queued_bytes{parent=none,queue=customer-1,target=192.168.88.10/32}":              1500,
+				"mikrotik_queue_simple_upload_max_limit_bits_per_second{parent=none,queue=customer-1,target=192.168.88.10/32}":   10000000,
+				"mikrotik_queue_simple_download_max_limit_bits_per_second{parent=none,queue=customer-1,target=192.168.88.10/32}": 50000000,
+				"mikrotik_queue_tree_bytes{parent=global,queue=download,target=}":                                                30000,
+				"mikrotik_queue_tree_dropped_packets{parent=global,queue=download,target=}":                                      5,
+				"mikrotik_queue_tree_queued_packets{parent=global,queue=download,target=}":                                       2,
+				"mikrotik_queue_tree_max_limit_bits_per_second{parent=global,queue=download,target=}":                            0,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		s := newTestServer(t)
+		for command, r := range tc.replies {
+			s.Handle(command, r)
+		}
+
+		cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+		metrics := collectMetrics(t, newTestCollector(t, cfg, tc.option))
+		s.Close()
+
+		assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector="+tc.collector+"}"], tc.collector)
+		for name, v := range tc.expected {
+			value, found := metrics[name]
+			if assert.True(t, found, "%s: missing %s", tc.collector, name) {
+				assert.Equal(t, v, value, "%s: %s", tc.collector, name)
+			}
+		}
+	}
+}
+
+func TestCustomCollector(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/tool/netwatch/print ?disabled=false", routerostest.Reply{Re: re{
+		{"host": "8.8.8.8", "comment": "dns", "status": "up", "since": "1h"},
+		{"host": "10.0.0.1", "status": "down"},
+	}})
+
+	cfg := &config.Config{
+		Devices: []config.Device{testDevice(s, testPassword)},
+		CustomCollectors: []config.CustomCollector{{
+			Name:    "netwatch",
+			Command: "/tool/netwatch/print",
+			Filters: []string{"?disabled=false"},
+			Labels:  []config.CustomLabel{{Property: "host"}, {Property: "comment", Label: "description"}},
+			Metrics: []config.CustomMetric{
+				{Property: "status", Name: "up", Parser: config.ParserBoolean},
+				{Property: "since", Parser: config.ParserDuration},
+			},
+		}},
+	}
+	metrics := collectMetrics(t, newTestCollector(t, cfg))
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=netwatch}"])
+	assert.Equal(t, 1.0, metrics["mikrotik_netwatch_up{description=dns,host=8.8.8.8}"])
+	assert.Equal(t, 0.0, metrics["mikrotik_netwatch_up{description=,host=10.0.0.1}"])
+	assert.Equal(t, 3600.0, metrics["mikrotik_netwatch_since{description=dns,host=8.8.8.8}"])
+
+	_, found := metrics["mikrotik_netwatch_since{description=,host=10.0.0.1}"]
+	assert.False(t, found)
+
+	commands := s.Commands()
+	assert.Contains(t, commands, []string{"/tool/netwatch/print", "?disabled=false", "=.proplist=host,comment,status,since"})
+}
+
+func TestFirewallCollectorShouldLeaveOutUncommentedRules(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	for _, table := range []string{"filter", "mangle", "raw"} {
+		s.Handle("/ipv6/firewall/"+table+"/print ?disabled=false", routerostest.Reply{Re: re{
+			{"chain": "forward", "action": "accept", "bytes": "1000", "packets": "10"},
+		}})
+	}
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg, WithFirewallV6(true)))
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=firewallv6}"])
+	for name := range metrics {
+		assert.NotContains(t, name, "mikrotik_firewall_rule_")
+	}
+	for _, cmd := range s.Commands() {
+		assert.NotEqual(t, "/ipv6/firewall/nat/print", cmd[0], "IPv6 NAT requires RouterOS 7")
+	}
+}
+
+func TestConntrackCollectorShouldExportTopSourcesOnly(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/ip/firewall/connection/tracking/print", routerostest.Reply{})
+	s.Handle("/ip/firewall/connection/print", routerostest.Reply{
+		Re:   re{{"src-address": "10.0.0.5:1"}, {"src-address": "10.0.0.6:1"}, {"src-address": "10.0.0.6:2"}},
+		Done: map[string]string{"ret": "0"},
+	})
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg, WithConntrack(1)))
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=conntrack}"])
+	assert.Equal(t, 2.0, metrics["mikrotik_conntrack_src_address_connections{src_address=10.0.0.6}"])
+	_, found := metrics["mikrotik_conntrack_src_address_connections{src_address=10.0.0.5}"]
+	assert.False(t, found)
+}
+
+func TestOSPFNeighborCollectorShouldSkipMissingOSPFv3Menu(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	// without the ipv6 package, RouterOS 6 has no /routing/ospf-v3 menu
+	s.Handle("/routing/ospf/neighbor/print", routerostest.Reply{Re: re{{
+		"instance":  "default",
+		"router-id": "10.0.0.2",
+		"address":   "10.0.0.2",
+		"interface": "ether2",
+		"state":     "Full",
+	}}})
+	s.Handle("/routing/ospf/interface/print", routerostest.Reply{})
+	s.Handle("/routing/ospf/lsa/print", routerostest.Reply{})
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg, WithOSPFNeighbor()))
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=ospf-neighbor}"])
+	assert.Equal(t, 8.0, metrics["mikrotik_ospf_neighbor_state{area=,instance=default,interface=ether2,neighbor_address=10.0.0.2,ospf_version=2,router_id=10.0.0.2}"])
+	for name := range metrics {
+		assert.NotContains(t, name, "mikrotik_scrape_errors_total", "the missing menu is not an error")
+	}
+}
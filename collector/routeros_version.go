@@ -0,0 +1,154 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// errUnsupported is returned for collectors which do not support the RouterOS
+// version of the device. Those collectors are skipped without reporting them.
+var errUnsupported = errors.New("not supported by RouterOS version")
+
+var versionRegex = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+var (
+	routerOSInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "routeros", "info"),
+		"mikrotik_exporter: RouterOS version detected on the device",
+		[]string{"device", "version", "major"},
+		nil,
+	)
+	routerOSCapabilityDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "routeros", "capability"),
+		"mikrotik_exporter: capabilities of the RouterOS version of the device",
+		[]string{"device", "capability"},
+		nil,
+	)
+)
+
+// routerOSVersion is the version reported by /system/resource. The zero value
+// is an unknown version.
+type routerOSVersion struct {
+	raw   string
+	major int
+	minor int
+}
+
+func parseVersion(s string) (routerOSVersion, error) {
+	m := versionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return routerOSVersion{}, fmt.Errorf("invalid RouterOS version %q", s)
+	}
+
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+
+	return routerOSVersion{raw: s, major: major, minor: minor}, nil
+}
+
+func (v routerOSVersion) known() bool {
+	return v.major > 0
+}
+
+// capability is a set of API menus available in a range of major versions
+type capability struct {
+	name     string
+	minMajor int
+	maxMajor int
+}
+
+var (
+	// capRoutingV6 covers the v6 menus /routing/bgp/peer and
+	// /routing/ospf/neighbor
+	capRoutingV6 = capability{name: "routing-v6", maxMajor: 6}
+
+	// capRoutingV7 covers the v7 menus, e.g. /routing/bgp/session and
+	// /routing/ospf/neighbor with the v7 properties, and the boolean protocol
+	// properties of /ip/route and /ipv6/route
+	capRoutingV7 = capability{name: "routing-v7", minMajor: 7}
+
+	// capIPv6NAT covers /ipv6/firewall/nat
+	capIPv6NAT = capability{name: "ipv6-nat", minMajor: 7}
+
+	capabilities = []capability{capRoutingV6, capRoutingV7, capIPv6NAT}
+)
+
+// supportedBy returns whether the version has the capability. Devices of
+// unknown version are assumed to run RouterOS 6, as the exporter did before
+// detecting versions.
+func (c capability) supportedBy(v routerOSVersion) bool {
+	major := v.major
+	if !v.known() {
+		major = 6
+	}
+
+	return major >= c.minMajor && (c.maxMajor == 0 || major <= c.maxMajor)
+}
+
+// versionedCollector is implemented by collectors which only support some
+// RouterOS versions. Collectors not implementing it support all versions.
+type versionedCollector interface {
+	supportedBy(v routerOSVersion) bool
+}
+
+func collectorSupports(co routerOSCollector, v routerOSVersion) bool {
+	vc, ok := co.(versionedCollector)
+	if !ok {
+		return true
+	}
+
+	return vc.supportedBy(v)
+}
+
+// detectVersion reads the RouterOS version of the device. A version which can
+// not be parsed is logged and treated as unknown.
+func detectVersion(client commandRunner, device string) (routerOSVersion, error) {
+	reply, err := client.Run("/system/resource/print", "=.proplist=version")
+	if err != nil {
+		return routerOSVersion{}, err
+	}
+
+	if len(reply.Re) == 0 {
+		return routerOSVersion{}, nil
+	}
+
+	v, err := parseVersion(reply.Re[0].Map["version"])
+	if err != nil {
+		log.WithFields(log.Fields{
+			"device": device,
+			"error":  err,
+		}).Warn("could not detect RouterOS version")
+		return routerOSVersion{}, nil
+	}
+
+	log.WithFields(log.Fields{
+		"device":  device,
+		"version": v.raw,
+	}).Debug("detected RouterOS version")
+
+	return v, nil
+}
+
+// versionMetrics returns the info and capability metrics of a known version
+func versionMetrics(device string, v routerOSVersion) []prometheus.Metric {
+	if !v.known() {
+		return nil
+	}
+
+	metrics := []prometheus.Metric{
+		prometheus.MustNewConstMetric(routerOSInfoDesc, prometheus.GaugeValue, 1, device, v.raw, strconv.Itoa(v.major)),
+	}
+
+	for _, c := range capabilities {
+		if c.supportedBy(v) {
+			metrics = append(metrics, prometheus.MustNewConstMetric(routerOSCapabilityDesc, prometheus.GaugeValue, 1, device, c.name))
+		}
+	}
+
+	return metrics
+}
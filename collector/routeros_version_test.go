@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"mikrotik-exporter/collector/routerostest"
+	"mikrotik-exporter/config"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		value string
+		major int
+		minor int
+		err   bool
+	}{
+		{"6.48.6 (long-term)", 6, 48, false},
+		{"7.12.1 (stable)", 7, 12, false},
+		{"7.13beta2 (testing)", 7, 13, false},
+		{"unknown", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		v, err := parseVersion(test.value)
+		if test.err {
+			assert.Error(t, err, test.value)
+			continue
+		}
+
+		assert.NoError(t, err, test.value)
+		assert.Equal(t, test.major, v.major, test.value)
+		assert.Equal(t, test.minor, v.minor, test.value)
+	}
+}
+
+func TestCapabilitySupportedBy(t *testing.T) {
+	v6 := routerOSVersion{raw: "6.48.6", major: 6, minor: 48}
+	v7 := routerOSVersion{raw: "7.12.1", major: 7, minor: 12}
+
+	assert.True(t, capRoutingV6.supportedBy(v6))
+	assert.False(t, capRoutingV6.supportedBy(v7))
+	assert.False(t, capRoutingV7.supportedBy(v6))
+	assert.True(t, capRoutingV7.supportedBy(v7))
+	assert.True(t, capRoutingV6.supportedBy(routerOSVersion{}), "unknown versions are assumed to be 6")
+	assert.False(t, capRoutingV7.supportedBy(routerOSVersion{}), "unknown versions are assumed to be 6")
+}
+
+func TestCollectShouldSkipUnsupportedCollectors(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/system/resource/print", routerostest.Reply{Re: re{{
+		"free-memory":  "1024",
+		"total-memory": "4096",
+		"cpu-load":     "7",
+		"uptime":       "1d2h",
+		"board-name":   "CCR2004",
+		"version":      "7.12.1 (stable)",
+	}}})
+	s.Handle("/routing/bgp/session/print", routerostest.Reply{})
+	s.Handle("/routing/ospf/instance/print", routerostest.Reply{Re: re{{"name": "default-v2", "version": "2"}}})
+	s.Handle("/routing/ospf/neighbor/print", routerostest.Reply{})
+	s.Handle("/routing/ospf/interface/print", routerostest.Reply{})
+	s.Handle("/routing/ospf/lsa/print", routerostest.Reply{})
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg, WithBGP(), WithOSPFNeighbor()))
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=bgp}"], "only the v7 collector reports bgp")
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=ospf-neighbor}"], "only the v7 collector reports ospf-neighbor")
+	assert.Equal(t, 1.0, metrics["mikrotik_routeros_info{major=7,version=7.12.1 (stable)}"])
+	assert.Equal(t, 1.0, metrics["mikrotik_routeros_capability{capability=routing-v7}"])
+	assert.NotContains(t, metrics, "mikrotik_routeros_capability{capability=routing-v6}")
+
+	// RouterOS 7 still has /routing/ospf/neighbor, but with other properties
+	// than RouterOS 6 and without the separate OSPFv3 menu
+	sent := make(map[string]bool)
+	for _, cmd := range s.Commands() {
+		sent[cmd[0]] = true
+		assert.NotEqual(t, "/routing/bgp/peer/print", cmd[0])
+		assert.False(t, strings.HasPrefix(cmd[0], "/routing/ospf-v3/"), "unexpected command %v", cmd)
+		if cmd[0] == "/routing/ospf/neighbor/print" {
+			assert.NotContains(t, cmd[len(cmd)-1], "dr-address", "RouterOS 6 properties queried")
+		}
+	}
+	assert.True(t, sent["/routing/ospf/instance/print"])
+	assert.True(t, sent["/routing/ospf/neighbor/print"])
+}
+
+func TestCollectShouldDetectVersionOncePerConnection(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	c := newTestCollector(t, cfg)
+	collectMetrics(t, c)
+	metrics := collectMetrics(t, c)
+
+	assert.Equal(t, 1.0, metrics["mikrotik_routeros_info{major=6,version=6.45.9 (long-term)}"])
+	assert.Equal(t, 1.0, metrics["mikrotik_routeros_capability{capability=routing-v6}"])
+
+	detections := 0
+	for _, cmd := range s.Commands() {
+		if len(cmd) == 2 && cmd[0] == "/system/resource/print" && cmd[1] == "=.proplist=version" {
+			detections++
+		}
+	}
+	assert.Equal(t, 1, detections)
+}
+
+func TestCollectShouldRunCollectorsIfVersionIsRefused(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/system/resource/print", routerostest.Reply{Trap: "not enough permissions (9)"})
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	c := newTestCollector(t, cfg, WithBGP())
+	collectMetrics(t, c)
+	metrics := collectMetrics(t, c)
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=interface}"])
+	assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+	assert.Contains(t, metrics, "mikrotik_scrape_collector_success{collector=bgp}")
+	assert.NotContains(t, metrics, "mikrotik_routeros_info{major=6,version=6.45.9 (long-term)}")
+}
+
+func TestSupportedCollectorsShouldHaveUniqueNames(t *testing.T) {
+	f := &config.Features{BGP: true, OSPFNeighbor: true}
+	collectors := collectorsForFeatures(f)
+
+	versions := []routerOSVersion{{}, {raw: "6.45.9", major: 6, minor: 45}, {raw: "7.12.1", major: 7, minor: 12}}
+	for _, v := range versions {
+		names := make(map[string]routerOSCollector)
+		for _, co := range collectors {
+			if !collectorSupports(co, v) {
+				continue
+			}
+
+			if other, found := names[co.name()]; found {
+				t.Errorf("version %q: %T and %T are both named %s", v.raw, other, co, co.name())
+			}
+			names[co.name()] = co
+		}
+
+		if v.major == 7 {
+			assert.IsType(t, &bgpSessionCollector{}, names["bgp"])
+		} else {
+			assert.IsType(t, &bgpCollector{}, names["bgp"])
+		}
+
+		ospf, _ := names["ospf-neighbor"].(*ospfNeighborCollector)
+		if assert.NotNil(t, ospf) {
+			if v.major == 7 {
+				assert.Equal(t, capRoutingV7, ospf.capability)
+			} else {
+				assert.Equal(t, capRoutingV6, ospf.capability)
+			}
+		}
+	}
+}
@@ -0,0 +1,365 @@
+// Package routerostest provides an in-process RouterOS API server for testing
+// collectors without a real device.
+package routerostest
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"gopkg.in/routeros.v2/proto"
+)
+
+// challenge is sent to clients using the legacy login
+var challenge = []byte("routerostest-cha")
+
+// Reply represents the canned reply to a command. Each item of Re is sent as
+// a !re sentence, followed by a !done sentence with the attributes of Done.
+// If Trap is set, a !trap sentence with this message is sent instead of the
+// items. If Empty is set and there are no items, an !empty sentence is sent
+// before !done like RouterOS 7.18 and later do.
+type Reply struct {
+	Re    []map[string]string
+	Done  map[string]string
+	Trap  string
+	Empty bool
+}
+
+// Server is a RouterOS API server listening on a local port
+type Server struct {
+	// User and Password are the credentials accepted by /login
+	User     string
+	Password string
+
+	// LegacyLogin enables the challenge response login used before RouterOS
+	// 6.43 instead of the plain text login
+	LegacyLogin bool
+
+	listener net.Listener
+	mu       sync.Mutex
+	replies  map[string]Reply
+	commands [][]string
+	conns    map[net.Conn]struct{}
+	logins   int
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server accepting the given credentials
+func NewServer(user, password string) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		User:     user,
+		Password: password,
+		listener: l,
+		replies:  make(map[string]Reply),
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Handle sets the reply to a command. The command is given as the words of
+// the sentence separated by spaces, e.g. "/ip/pool/used/print ?pool=a
+// =count-only=". The .proplist attribute is ignored when matching. Commands
+// without an exact match are answered with the reply registered for the
+// command word only, if any. Unknown commands are answered with a trap like
+// RouterOS does.
+func (s *Server) Handle(command string, r Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replies[command] = r
+}
+
+// Commands returns all sentences received after login, in order
+func (s *Server) Commands() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([][]string{}, s.commands...)
+}
+
+// Logins returns the number of successful logins
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logins
+}
+
+// CloseConnections closes all client connections, e.g. to simulate a reboot
+// of the device
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops the server and closes all client connections
+func (s *Server) Close() {
+	s.listener.Close()
+	s.CloseConnections()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := proto.NewWriter(conn)
+
+	loggedIn := false
+	for {
+		words, err := readSentence(r)
+		if err != nil {
+			return
+		}
+
+		if len(words) == 0 {
+			continue
+		}
+
+		if words[0] == "/login" {
+			loggedIn = s.login(w, attributes(words))
+			continue
+		}
+
+		if !loggedIn {
+			writeTrap(w, "not logged in")
+			if err := writeDone(w, nil); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err := s.reply(w, words); err != nil {
+			return
+		}
+	}
+}
+
+// login handles both login styles. With the legacy login, the first /login
+// is answered with a challenge, regardless of the attributes sent.
+func (s *Server) login(w proto.Writer, attrs map[string]string) bool {
+	if s.LegacyLogin {
+		response, found := attrs["response"]
+		if !found {
+			writeDone(w, map[string]string{"ret": hex.EncodeToString(challenge)})
+			return false
+		}
+
+		if attrs["name"] == s.User && response == challengeResponse(challenge, s.Password) {
+			s.loginSucceeded()
+			writeDone(w, nil)
+			return true
+		}
+	} else if attrs["name"] == s.User && attrs["password"] == s.Password {
+		s.loginSucceeded()
+		writeDone(w, nil)
+		return true
+	}
+
+	writeTrap(w, "invalid user name or password (6)")
+	writeDone(w, nil)
+	return false
+}
+
+func (s *Server) loginSucceeded() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logins++
+}
+
+func (s *Server) reply(w proto.Writer, words []string) error {
+	s.mu.Lock()
+	s.commands = append(s.commands, words)
+	r, found := s.lookup(words)
+	s.mu.Unlock()
+
+	if !found {
+		writeTrap(w, "no such command prefix")
+		return writeDone(w, nil)
+	}
+
+	if r.Trap != "" {
+		writeTrap(w, r.Trap)
+		return writeDone(w, nil)
+	}
+
+	for _, re := range r.Re {
+		w.BeginSentence()
+		w.WriteWord("!re")
+		for k, v := range re {
+			w.WriteWord("=" + k + "=" + v)
+		}
+		if err := w.EndSentence(); err != nil {
+			return err
+		}
+	}
+
+	if r.Empty && len(r.Re) == 0 {
+		w.BeginSentence()
+		w.WriteWord("!empty")
+		if err := w.EndSentence(); err != nil {
+			return err
+		}
+	}
+
+	return writeDone(w, r.Done)
+}
+
+func (s *Server) lookup(words []string) (Reply, bool) {
+	key := make([]string, 0, len(words))
+	for _, word := range words {
+		if !strings.HasPrefix(word, "=.proplist=") {
+			key = append(key, word)
+		}
+	}
+
+	if r, found := s.replies[strings.Join(key, " ")]; found {
+		return r, true
+	}
+
+	r, found := s.replies[words[0]]
+	return r, found
+}
+
+func attributes(words []string) map[string]string {
+	attrs := make(map[string]string)
+	for _, word := range words[1:] {
+		if !strings.HasPrefix(word, "=") {
+			continue
+		}
+
+		kv := strings.SplitN(word[1:], "=", 2)
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+		attrs[kv[0]] = kv[1]
+	}
+
+	return attrs
+}
+
+// readSentence reads the words of a sentence. Unlike proto.Reader it accepts
+// the query words sent by clients.
+func readSentence(r *bufio.Reader) ([]string, error) {
+	var words []string
+	for {
+		l, err := readLength(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if l == 0 {
+			return words, nil
+		}
+
+		b := make([]byte, l)
+		_, err = io.ReadFull(r, b)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, string(b))
+	}
+}
+
+func readLength(r *bufio.Reader) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var extra int
+	l := int(first)
+	switch {
+	case first&0x80 == 0x00:
+	case first&0xC0 == 0x80:
+		extra, l = 1, l&^0xC0
+	case first&0xE0 == 0xC0:
+		extra, l = 2, l&^0xE0
+	case first&0xF0 == 0xE0:
+		extra, l = 3, l&^0xF0
+	default:
+		extra, l = 4, 0
+	}
+
+	for i := 0; i < extra; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		l = l<<8 | int(b)
+	}
+
+	return l, nil
+}
+
+func writeTrap(w proto.Writer, message string) {
+	w.BeginSentence()
+	w.WriteWord("!trap")
+	w.WriteWord("=message=" + message)
+	w.EndSentence()
+}
+
+func writeDone(w proto.Writer, attrs map[string]string) error {
+	w.BeginSentence()
+	w.WriteWord("!done")
+	for k, v := range attrs {
+		w.WriteWord("=" + k + "=" + v)
+	}
+	return w.EndSentence()
+}
+
+func challengeResponse(cha []byte, password string) string {
+	h := md5.New()
+	h.Write([]byte{0})
+	io.WriteString(h, password)
+	h.Write(cha)
+	return fmt.Sprintf("00%x", h.Sum(nil))
+}
@@ -29,6 +29,10 @@ func (c *routesCollector) init() {
 	c.protocols = []string{"bgp", "static", "ospf", "dynamic", "connect"}
 }
 
+func (c *routesCollector) name() string {
+	return "routes"
+}
+
 func (c *routesCollector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.countDesc
 	ch <- c.countProtocolDesc
@@ -85,7 +89,7 @@ func (c *routesCollector) colllectCount(ipVersion, topic string, ctx *collectorC
 }
 
 func (c *routesCollector) colllectCountProtcol(ipVersion, topic, protocol string, ctx *collectorContext) error {
-	reply, err := ctx.client.Run(fmt.Sprintf("/%s/route/print", topic), "?disabled=false", fmt.Sprintf("?%s", protocol), "=count-only=")
+	reply, err := ctx.client.Run(fmt.Sprintf("/%s/route/print", topic), "?disabled=false", routeProtocolFilter(protocol, ctx.version), "=count-only=")
 	if err != nil {
 		log.WithFields(log.Fields{
 			"ip_version": ipVersion,
@@ -110,3 +114,14 @@ func (c *routesCollector) colllectCountProtcol(ipVersion, topic, protocol string
 	ctx.ch <- prometheus.MustNewConstMetric(c.countProtocolDesc, prometheus.GaugeValue, v, ctx.device.Name, ctx.device.Address, protocol)
 	return nil
 }
+
+// routeProtocolFilter returns the query for routes of a protocol. RouterOS 6
+// only sets the property of the protocol of a route, RouterOS 7 sets the
+// properties of all protocols to true or false.
+func routeProtocolFilter(protocol string, v routerOSVersion) string {
+	if capRoutingV7.supportedBy(v) {
+		return fmt.Sprintf("?%s=true", protocol)
+	}
+
+	return fmt.Sprintf("?%s", protocol)
+}
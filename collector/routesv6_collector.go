@@ -29,6 +29,10 @@ func (c *routesV6Collector) init() {
 	c.protocols = []string{"bgp", "static", "ospf", "dynamic", "connect"}
 }
 
+func (c *routesV6Collector) name() string {
+	return "routesv6"
+}
+
 func (c *routesV6Collector) describe(ch chan<- *prometheus.Desc) {
 	ch <- c.countDesc
 	ch <- c.countProtocolDesc
@@ -85,7 +89,7 @@ func (c *routesV6Collector) collectCount(ipVersion, topic string, ctx *collector
 }
 
 func (c *routesV6Collector) collectCountProtocol(ipVersion, topic, protocol string, ctx *collectorContext) error {
-	reply, err := ctx.client.Run(fmt.Sprintf("/%s/route/print", topic), "?disabled=false", fmt.Sprintf("?%s", protocol), "=count-only=")
+	reply, err := ctx.client.Run(fmt.Sprintf("/%s/route/print", topic), "?disabled=false", routeProtocolFilter(protocol, ctx.version), "=count-only=")
 	if err != nil {
 		log.WithFields(log.Fields{
 			"ip_version": ipVersion,
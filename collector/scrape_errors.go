@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/routeros.v2"
+)
+
+const (
+	reasonDNS               = "dns"
+	reasonConnectionRefused = "connection_refused"
+	reasonTimeout           = "timeout"
+	reasonTLS               = "tls"
+	reasonAuth              = "auth"
+	reasonPermission        = "permission"
+	reasonUnknownCommand    = "unknown_command"
+	reasonParse             = "parse"
+	reasonOther             = "other"
+)
+
+var scrapeErrorsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "errors_total"),
+	"mikrotik_exporter: number of errors while collecting a device by reason",
+	[]string{"device", "collector", "reason"},
+	nil,
+)
+
+// defaultScrapeErrors is shared by all collectors, so the counters survive
+// collectors created per request or on config changes
+var defaultScrapeErrors = newScrapeErrors()
+
+// authError marks login attempts rejected by the device
+type authError struct {
+	err error
+}
+
+func (e *authError) Error() string {
+	return fmt.Sprintf("login failed: %v", e.err)
+}
+
+type scrapeErrorKey struct {
+	collector string
+	reason    string
+}
+
+// scrapeErrors counts the errors of all devices by collector and reason
+type scrapeErrors struct {
+	mu      sync.Mutex
+	devices map[string]map[scrapeErrorKey]int
+}
+
+func newScrapeErrors() *scrapeErrors {
+	return &scrapeErrors{
+		devices: make(map[string]map[scrapeErrorKey]int),
+	}
+}
+
+func (s *scrapeErrors) add(device, collector, reason string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts, found := s.devices[device]
+	if !found {
+		counts = make(map[scrapeErrorKey]int)
+		s.devices[device] = counts
+	}
+
+	counts[scrapeErrorKey{collector, reason}] += n
+}
+
+// metrics returns the counters of the device
+func (s *scrapeErrors) metrics(device string) []prometheus.Metric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]scrapeErrorKey, 0, len(s.devices[device]))
+	for k := range s.devices[device] {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].collector != keys[j].collector {
+			return keys[i].collector < keys[j].collector
+		}
+		return keys[i].reason < keys[j].reason
+	})
+
+	metrics := make([]prometheus.Metric, 0, len(keys))
+	for _, k := range keys {
+		v := float64(s.devices[device][k])
+		metrics = append(metrics, prometheus.MustNewConstMetric(scrapeErrorsDesc, prometheus.CounterValue, v, device, k.collector, k.reason))
+	}
+
+	return metrics
+}
+
+// errorReason classifies an error of a collector, so e.g. an unreachable
+// device can be told apart from a user lacking permissions
+func errorReason(err error) string {
+	switch e := err.(type) {
+	case *dialError:
+		return errorReason(e.err)
+	case *backoffError:
+		return errorReason(e.err)
+	case *authError:
+		return reasonAuth
+	case *tlsHandshakeError:
+		return reasonTLS
+	case *net.DNSError:
+		return reasonDNS
+	case *net.OpError:
+		if se, ok := e.Err.(*os.SyscallError); ok && se.Err == syscall.ECONNREFUSED {
+			return reasonConnectionRefused
+		}
+		if e.Err != nil {
+			if reason := errorReason(e.Err); reason != reasonOther {
+				return reason
+			}
+		}
+	case *routeros.DeviceError:
+		return deviceErrorReason(e)
+	case *strconv.NumError:
+		return reasonParse
+	}
+
+	if err == errScrapeTimeout {
+		return reasonTimeout
+	}
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return reasonTimeout
+	}
+
+	return reasonOther
+}
+
+// isUnknownCommand returns whether the device rejected a command it does not
+// know, e.g. a menu of a disabled package
+func isUnknownCommand(err error) bool {
+	de, ok := err.(*routeros.DeviceError)
+	return ok && deviceErrorReason(de) == reasonUnknownCommand
+}
+
+// deviceErrorReason classifies the message of a !trap sent by the device
+func deviceErrorReason(err *routeros.DeviceError) string {
+	msg := strings.ToLower(err.Sentence.Map["message"])
+
+	switch {
+	case strings.Contains(msg, "not enough permissions"), strings.Contains(msg, "policy"):
+		return reasonPermission
+	case strings.Contains(msg, "no such command"):
+		return reasonUnknownCommand
+	}
+
+	return reasonOther
+}
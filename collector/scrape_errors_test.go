@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/routeros.v2"
+	"gopkg.in/routeros.v2/proto"
+	"mikrotik-exporter/collector/routerostest"
+	"mikrotik-exporter/config"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func deviceError(message string) error {
+	return &routeros.DeviceError{Sentence: &proto.Sentence{Map: map[string]string{"message": message}}}
+}
+
+func TestErrorReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"dns", &dialError{&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "router"}}}, reasonDNS},
+		{"dns timeout", &dialError{&net.OpError{Op: "dial", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}}, reasonDNS},
+		{"timeout", &net.OpError{Op: "read", Err: timeoutError{}}, reasonTimeout},
+		{"scrape timeout", errScrapeTimeout, reasonTimeout},
+		{"tls", &dialError{&tlsHandshakeError{errors.New("x509: certificate has expired")}}, reasonTLS},
+		{"auth", &dialError{&authError{deviceError("invalid user name or password (6)")}}, reasonAuth},
+		{"backoff", &dialError{&backoffError{err: &authError{deviceError("cannot log in")}}}, reasonAuth},
+		{"permission", deviceError("not enough permissions (9)"), reasonPermission},
+		{"unknown command", deviceError("no such command prefix"), reasonUnknownCommand},
+		{"parse", &strconv.NumError{Func: "ParseFloat", Num: "x", Err: strconv.ErrSyntax}, reasonParse},
+		{"other trap", deviceError("failure: interface not found"), reasonOther},
+		{"other", errors.New("unexpected"), reasonOther},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, errorReason(test.err), test.name)
+	}
+}
+
+func TestCollectShouldCountErrorsByReason(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/routing/bgp/peer/print", routerostest.Reply{Trap: "not enough permissions (9)"})
+	s.Handle("/ip/pool/print", routerostest.Reply{Trap: "no such command prefix"})
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	c := newTestCollector(t, cfg, WithBGP(), WithPool())
+	collectMetrics(t, c)
+	metrics := collectMetrics(t, c)
+
+	assert.Equal(t, 2.0, metrics["mikrotik_scrape_errors_total{collector=bgp,reason=permission}"])
+	assert.Equal(t, 2.0, metrics["mikrotik_scrape_errors_total{collector=pool,reason=unknown_command}"])
+	assert.NotContains(t, metrics, "mikrotik_scrape_errors_total{collector=resource,reason=other}")
+}
+
+func TestCollectShouldCountAuthErrors(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, "wrong")}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg))
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_errors_total{collector=connect,reason=auth}"])
+	assert.NotContains(t, metrics, "mikrotik_scrape_errors_total{collector=interface,reason=auth}")
+	assert.NotContains(t, metrics, "mikrotik_scrape_errors_total{collector=resource,reason=auth}")
+	assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=interface}"])
+	assert.Equal(t, 0.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+}
+
+func TestCollectShouldCountRefusedConnections(t *testing.T) {
+	s := newTestServer(t)
+	d := testDevice(s, testPassword)
+	s.Close()
+
+	cfg := &config.Config{Devices: []config.Device{d}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg))
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_errors_total{collector=connect,reason=connection_refused}"])
+	assert.NotContains(t, metrics, "mikrotik_scrape_errors_total{collector=resource,reason=connection_refused}")
+}
+
+func TestCollectShouldCountParseErrors(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.Handle("/system/resource/print", routerostest.Reply{Re: re{{
+		"free-memory":  "lots",
+		"total-memory": "4096",
+		"cpu-load":     "7",
+		"uptime":       "1d2h",
+	}}})
+
+	cfg := &config.Config{Devices: []config.Device{testDevice(s, testPassword)}}
+	metrics := collectMetrics(t, newTestCollector(t, cfg))
+
+	assert.Equal(t, 1.0, metrics["mikrotik_scrape_collector_success{collector=resource}"])
+	assert.True(t, metrics["mikrotik_scrape_errors_total{collector=resource,reason=parse}"] >= 1)
+}
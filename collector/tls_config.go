@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"mikrotik-exporter/config"
+)
+
+// tlsHandshakeError marks errors which occurred during the TLS handshake, e.g.
+// an untrusted or expired certificate of the device
+type tlsHandshakeError struct {
+	err error
+}
+
+func (e *tlsHandshakeError) Error() string {
+	return fmt.Sprintf("TLS handshake failed: %v", e.err)
+}
+
+// newTLSConfig creates the TLS client config to connect to the given address.
+// Certificate files are read on every call, so renewed certificates are picked
+// up on the next connect.
+func newTLSConfig(t *config.TLSConfig, address string) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		ServerName:         t.ServerName,
+		MinVersion:         config.TLSVersions[t.MinVersion],
+	}
+
+	if tlsCfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ServerName = host
+	}
+
+	if t.CAFile != "" {
+		b, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %v", err)
+		}
+
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %v", err)
+		}
+
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// handshakeTLS starts a TLS session on an established connection
+func handshakeTLS(conn net.Conn, tlsCfg *tls.Config, timeout time.Duration) (net.Conn, error) {
+	tlsConn := tls.Client(conn, tlsCfg)
+
+	err := tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	err = tlsConn.Handshake()
+	if err != nil {
+		conn.Close()
+		return nil, &tlsHandshakeError{err}
+	}
+
+	return tlsConn, nil
+}
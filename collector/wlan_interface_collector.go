@@ -30,6 +30,10 @@ func (c *wlanIFCollector) init() {
 	}
 }
 
+func (c *wlanIFCollector) name() string {
+	return "wlan-interfaces"
+}
+
 func (c *wlanIFCollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -106,6 +110,7 @@ func (c *wlanIFCollector) collectMetricForProperty(property, iface string, re *p
 				"device":    ctx.device.Name,
 				"error":     err,
 			}).Error("error parsing interface metric value")
+			ctx.parseError()
 			return
 		}
 	}
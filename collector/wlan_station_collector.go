@@ -33,6 +33,10 @@ func (c *wlanSTACollector) init() {
 	}
 }
 
+func (c *wlanSTACollector) name() string {
+	return "wlan-stations"
+}
+
 func (c *wlanSTACollector) describe(ch chan<- *prometheus.Desc) {
 	for _, d := range c.descriptions {
 		ch <- d
@@ -99,6 +103,7 @@ func (c *wlanSTACollector) collectMetricForProperty(property, iface, mac string,
 			"value":    re.Map[property],
 			"error":    err,
 		}).Error("error parsing wlan station metric value")
+		ctx.parseError()
 		return
 	}
 
@@ -115,6 +120,7 @@ func (c *wlanSTACollector) collectMetricForTXRXCounters(property, iface, mac str
 			"value":    re.Map[property],
 			"error":    err,
 		}).Error("error parsing wlan station metric value")
+		ctx.parseError()
 		return
 	}
 	desc_tx := c.descriptions["tx_"+property]
@@ -1,52 +1,201 @@
 package config
 
 import (
+	"crypto/tls"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 // Config represents the configuration for the exporter
 type Config struct {
-	Devices  []Device `yaml:"devices"`
-	Features struct {
-		BGP            bool `yaml:"bgp,omitempty"`
-		DHCP           bool `yaml:"dhcp,omitempty"`
-		DHCPLeases     bool `yaml:"dhcp-leases,omitempty"`
-		DHCPv6         bool `yaml:"dhcpv6,omitempty"`
-		Routes         bool `yaml:"routes,omitempty"`
-		RoutesV6       bool `yaml:"routesv6,omitempty"`
-		Pool           bool `yaml:"pool,omitempty"`
-		PoolV6         bool `yaml:"poolv6,omitempty"`
-		Optics         bool `yaml:"optics,omitempty"`
-		WlanStations   bool `yaml:"wlan-stations,omitempty"`
-		WlanInterfaces bool `yaml:"wlan-interfaces,omitempty"`
-		Monitor        bool `yaml:"monitor,omitempty"`
-		IPSecPeers     bool `yaml:"ipsec-peers,omitempty"`
-		OSPFNeighbor   bool `yaml:"ospf-neighbor,omitempty"`
-	} `yaml:"features,omitempty"`
+	Devices          []Device                     `yaml:"devices"`
+	Features         Features                     `yaml:"features,omitempty"`
+	FeatureProfiles  map[string]Features          `yaml:"feature_profiles,omitempty"`
+	TLS              *TLSConfig                   `yaml:"tls,omitempty"`
+	Credentials      map[string]CredentialProfile `yaml:"credentials,omitempty"`
+	CustomCollectors []CustomCollector            `yaml:"custom_collectors,omitempty"`
+}
+
+// Features represents the optional collectors enabled for a device
+type Features struct {
+	BGP            bool `yaml:"bgp,omitempty"`
+	DHCP           bool `yaml:"dhcp,omitempty"`
+	DHCPLeases     bool `yaml:"dhcp-leases,omitempty"`
+	DHCPv6         bool `yaml:"dhcpv6,omitempty"`
+	Routes         bool `yaml:"routes,omitempty"`
+	RoutesV6       bool `yaml:"routesv6,omitempty"`
+	Pool           bool `yaml:"pool,omitempty"`
+	PoolV6         bool `yaml:"poolv6,omitempty"`
+	Optics         bool `yaml:"optics,omitempty"`
+	WlanStations   bool `yaml:"wlan-stations,omitempty"`
+	WlanInterfaces bool `yaml:"wlan-interfaces,omitempty"`
+	Monitor        bool `yaml:"monitor,omitempty"`
+	IPSecPeers     bool `yaml:"ipsec-peers,omitempty"`
+	OSPFNeighbor   bool `yaml:"ospf-neighbor,omitempty"`
+	Firewall       bool `yaml:"firewall,omitempty"`
+	FirewallV6     bool `yaml:"firewallv6,omitempty"`
+	Conntrack      bool `yaml:"conntrack,omitempty"`
+	Health         bool `yaml:"health,omitempty"`
+	Queue          bool `yaml:"queue,omitempty"`
+
+	// FirewallCommentedOnly limits the firewall collectors to rules with a
+	// comment
+	FirewallCommentedOnly bool `yaml:"firewall-commented-only,omitempty"`
+
+	// ConntrackTopN is the number of source addresses with the most
+	// connections to export, 0 disables counting connections per address
+	ConntrackTopN int `yaml:"conntrack-top-n,omitempty"`
 }
 
 // Device represents a target device
 type Device struct {
-	Name     string `yaml:"name"`
-	Address  string `yaml:"address"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
+	Name           string     `yaml:"name"`
+	Address        string     `yaml:"address"`
+	Port           int        `yaml:"port,omitempty"`
+	User           string     `yaml:"user"`
+	Password       string     `yaml:"password"`
+	PasswordFile   string     `yaml:"password_file,omitempty"`
+	PasswordEnv    string     `yaml:"password_env,omitempty"`
+	Features       *Features  `yaml:"features,omitempty"`
+	FeatureProfile string     `yaml:"feature_profile,omitempty"`
+	TLS            *TLSConfig `yaml:"tls,omitempty"`
+	Credentials    string     `yaml:"credentials,omitempty"`
+
+	// PollInterval replaces the interval of background polling for the device
+	PollInterval time.Duration `yaml:"poll_interval,omitempty"`
+
+	// Fallback holds the fallback credentials of the credential profile
+	Fallback []Credential `yaml:"-"`
+}
+
+// DefaultCredentials is the name of the credential profile used for probed
+// targets which are not configured as a device
+const DefaultCredentials = "default"
+
+// Credential represents a user and password used to log in to a device
+type Credential struct {
+	User         string `yaml:"user"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+	PasswordEnv  string `yaml:"password_env,omitempty"`
+}
+
+// CredentialProfile represents credentials shared by devices. The fallback
+// credentials are tried in order if logging in with the credentials fails,
+// e.g. while a password change is rolled out.
+type CredentialProfile struct {
+	Credential `yaml:",inline"`
+	Fallback   []Credential `yaml:"fallback,omitempty"`
+}
+
+// Apply sets the credentials of the profile on the device
+func (p *CredentialProfile) Apply(d *Device) {
+	d.User = p.User
+	d.Password = p.Password
+	d.Fallback = p.Fallback
+}
+
+// Logins returns the credentials to log in to the device with, in the order
+// they should be tried
+func (d *Device) Logins() []Credential {
+	return append([]Credential{{User: d.User, Password: d.Password}}, d.Fallback...)
 }
 
-// Load reads YAML from reader and unmashals in Config
+// TLSConfig represents the TLS settings used to connect to a device
+type TLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+	CAFile             string `yaml:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty"`
+	MinVersion         string `yaml:"min_version,omitempty"`
+}
+
+// TLSVersions maps the names allowed for min_version to the TLS versions
+var TLSVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// DeviceFeatures returns the features configured for a device, either directly
+// or by referencing a feature profile. It returns nil if the device uses the
+// global features.
+func (c *Config) DeviceFeatures(d *Device) *Features {
+	if d.Features != nil {
+		return d.Features
+	}
+
+	if d.FeatureProfile != "" {
+		if f, found := c.FeatureProfiles[d.FeatureProfile]; found {
+			return &f
+		}
+	}
+
+	return nil
+}
+
+// ReadPassword reads a password from a file or an environment variable.
+// Trailing line breaks are removed from passwords read from a file.
+func ReadPassword(file, env string) (string, error) {
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	p, found := os.LookupEnv(env)
+	if !found {
+		return "", fmt.Errorf("environment variable %s is not set", env)
+	}
+
+	return p, nil
+}
+
+// Load reads YAML from reader and unmashals in Config. Unknown keys are
+// rejected and the config is validated, all problems found are returned as a
+// *ValidationError.
 func Load(r io.Reader) (*Config, error) {
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
+	v := &validator{}
+
 	c := &Config{}
-	err = yaml.Unmarshal(b, c)
+	err = yaml.UnmarshalStrict(b, c)
 	if err != nil {
-		return nil, err
+		typeErr, ok := err.(*yaml.TypeError)
+		if !ok {
+			return nil, err
+		}
+
+		// the config is decoded as far as possible, so validation can still
+		// find the remaining problems
+		for _, e := range typeErr.Errors {
+			v.problems = append(v.problems, e)
+		}
+	}
+
+	v.validate(c)
+	if len(v.problems) == 0 {
+		v.resolveCredentials(c)
+	}
+
+	if len(v.problems) > 0 {
+		return nil, &ValidationError{Problems: v.problems}
 	}
 
 	return c, nil
@@ -3,7 +3,10 @@ package config
 import (
 	"bytes"
 	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestShouldParse(t *testing.T) {
@@ -19,6 +22,9 @@ func TestShouldParse(t *testing.T) {
 
 	assertDevice("test1", "192.168.1.1", "foo", "bar", c.Devices[0], t)
 	assertDevice("test2", "192.168.2.1", "test", "123", c.Devices[1], t)
+	if c.Devices[1].Port != 18728 {
+		t.Fatalf("expected port 18728, got %v", c.Devices[1].Port)
+	}
 	assertFeature("BGP", c.Features.BGP, t)
 	assertFeature("DHCP", c.Features.DHCP, t)
 	assertNoFeature("DHCPv6", c.Features.DHCPv6, t)
@@ -31,6 +37,421 @@ func TestShouldParse(t *testing.T) {
 	assertFeature("WlanInterfaces", c.Features.WlanInterfaces, t)
 }
 
+func TestShouldResolveDeviceFeatures(t *testing.T) {
+	b := loadTestFile(t)
+	c, err := Load(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	f := c.DeviceFeatures(&c.Devices[0])
+	if f == nil {
+		t.Fatalf("expected features for device %s", c.Devices[0].Name)
+	}
+	assertFeature("BGP", f.BGP, t)
+	assertFeature("OSPFNeighbor", f.OSPFNeighbor, t)
+	assertNoFeature("DHCP", f.DHCP, t)
+
+	f = c.DeviceFeatures(&c.Devices[1])
+	if f == nil {
+		t.Fatalf("expected features for device %s", c.Devices[1].Name)
+	}
+	assertFeature("WlanStations", f.WlanStations, t)
+	assertFeature("WlanInterfaces", f.WlanInterfaces, t)
+	assertNoFeature("BGP", f.BGP, t)
+
+	d := Device{Name: "test3"}
+	if f := c.DeviceFeatures(&d); f != nil {
+		t.Fatalf("expected no features for device %s, got %v", d.Name, f)
+	}
+}
+
+func TestShouldFailOnUnknownFeatureProfile(t *testing.T) {
+	y := `
+devices:
+  - name: test1
+    address: 192.168.1.1
+    user: foo
+    password: bar
+    feature_profile: missing
+`
+	_, err := Load(bytes.NewReader([]byte(y)))
+	if err == nil {
+		t.Fatalf("expected error for unknown feature profile")
+	}
+}
+
+func TestShouldParseTLS(t *testing.T) {
+	b := loadTestFile(t)
+	c, err := Load(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	tls := c.TLS
+	if tls == nil || !tls.Enabled {
+		t.Fatalf("expected global TLS settings")
+	}
+
+	if tls.CAFile != "/etc/ssl/internal-ca.pem" || tls.ServerName != "router.example.com" || tls.MinVersion != "TLS12" {
+		t.Fatalf("unexpected TLS settings %+v", tls)
+	}
+
+	if c.Devices[0].TLS != nil {
+		t.Fatalf("expected no TLS settings for device %s", c.Devices[0].Name)
+	}
+
+	tls = c.Devices[1].TLS
+	if tls == nil || tls.Enabled {
+		t.Fatalf("expected TLS to be disabled for device %s", c.Devices[1].Name)
+	}
+}
+
+func TestShouldFailOnInvalidTLS(t *testing.T) {
+	for _, y := range []string{`
+tls:
+  enabled: true
+  min_version: SSL3
+`, `
+tls:
+  enabled: true
+  cert_file: client.pem
+`} {
+		_, err := Load(bytes.NewReader([]byte(y)))
+		if err == nil {
+			t.Fatalf("expected error for invalid TLS settings in %s", y)
+		}
+	}
+}
+
+func TestShouldReportAllProblems(t *testing.T) {
+	y := `
+devices:
+  - name: test1
+    address: 192.168.1.1
+    user: foo
+    password: bar
+  - name: test1
+    user: foo
+    password: bar
+    feature_profile: missing
+
+features:
+  wlan-staions: true
+`
+	_, err := Load(bytes.NewReader([]byte(y)))
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	expected := []string{
+		"line 13: field wlan-staions not found in type config.Features",
+		"devices[1] (test1): address is required",
+		"devices[1] (test1): unknown feature profile missing",
+		"devices[1] (test1): duplicate device name, already used by devices[0]",
+	}
+	if len(verr.Problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), verr.Problems)
+	}
+
+	for i, p := range expected {
+		if verr.Problems[i] != p {
+			t.Fatalf("expected problem %q, got %q", p, verr.Problems[i])
+		}
+	}
+}
+
+func TestShouldReadPasswordsFromFileAndEnv(t *testing.T) {
+	f, err := ioutil.TempFile("", "password")
+	if err != nil {
+		t.Fatalf("could not create password file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("secret1\n")
+	if err != nil {
+		t.Fatalf("could not write password file: %v", err)
+	}
+	f.Close()
+
+	os.Setenv("MIKROTIK_TEST_PASSWORD", "secret2")
+	defer os.Unsetenv("MIKROTIK_TEST_PASSWORD")
+
+	y := `
+devices:
+  - name: test1
+    address: 192.168.1.1
+    user: foo
+    password_file: ` + f.Name() + `
+  - name: test2
+    address: 192.168.2.1
+    user: test
+    password_env: MIKROTIK_TEST_PASSWORD
+`
+	c, err := Load(bytes.NewReader([]byte(y)))
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	assertDevice("test1", "192.168.1.1", "foo", "secret1", c.Devices[0], t)
+	assertDevice("test2", "192.168.2.1", "test", "secret2", c.Devices[1], t)
+}
+
+func TestShouldFailOnMissingPasswordEnv(t *testing.T) {
+	y := `
+devices:
+  - name: test1
+    address: 192.168.1.1
+    user: foo
+    password_env: MIKROTIK_TEST_MISSING_PASSWORD
+`
+	_, err := Load(bytes.NewReader([]byte(y)))
+	if err == nil {
+		t.Fatalf("expected error for missing password environment variable")
+	}
+}
+
+func TestShouldApplyCredentialProfiles(t *testing.T) {
+	y := `
+devices:
+  - name: test1
+    address: 192.168.1.1
+    credentials: monitoring
+  - name: test2
+    address: 192.168.2.1
+    user: test
+    password: 123
+
+credentials:
+  monitoring:
+    user: prometheus
+    password: new
+    fallback:
+      - user: prometheus
+        password: old
+      - user: admin
+        password: older
+`
+	c, err := Load(bytes.NewReader([]byte(y)))
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	assertDevice("test1", "192.168.1.1", "prometheus", "new", c.Devices[0], t)
+	assertDevice("test2", "192.168.2.1", "test", "123", c.Devices[1], t)
+
+	expected := []Credential{
+		{User: "prometheus", Password: "new"},
+		{User: "prometheus", Password: "old"},
+		{User: "admin", Password: "older"},
+	}
+	logins := c.Devices[0].Logins()
+	if len(logins) != len(expected) {
+		t.Fatalf("expected %d logins, got %v", len(expected), logins)
+	}
+
+	for i, l := range expected {
+		if logins[i] != l {
+			t.Fatalf("expected login %v, got %v", l, logins[i])
+		}
+	}
+
+	if len(c.Devices[1].Logins()) != 1 {
+		t.Fatalf("expected 1 login, got %v", c.Devices[1].Logins())
+	}
+}
+
+func TestShouldFailOnInvalidCredentials(t *testing.T) {
+	y := `
+devices:
+  - name: test1
+    address: 192.168.1.1
+    credentials: missing
+  - name: test2
+    address: 192.168.2.1
+    user: foo
+    credentials: monitoring
+
+credentials:
+  monitoring:
+    user: prometheus
+    password: new
+    fallback:
+      - user: prometheus
+`
+	_, err := Load(bytes.NewReader([]byte(y)))
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	expected := []string{
+		"credentials.monitoring.fallback[0]: one of password, password_file or password_env is required",
+		"devices[0] (test1): unknown credentials missing",
+		"devices[1] (test2): credentials can not be used together with user or password",
+	}
+	if len(verr.Problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), verr.Problems)
+	}
+
+	for i, p := range expected {
+		if verr.Problems[i] != p {
+			t.Fatalf("expected problem %q, got %q", p, verr.Problems[i])
+		}
+	}
+}
+
+func TestShouldParseCustomCollectors(t *testing.T) {
+	y := `
+devices:
+  - name: test1
+    address: 192.168.1.1
+    user: foo
+    password: bar
+
+custom_collectors:
+  - name: netwatch
+    command: /tool/netwatch/print
+    filters:
+      - ?disabled=false
+    labels:
+      - host
+      - property: comment
+        label: description
+    metrics:
+      - property: status
+        name: up
+        help: whether the host is up
+        parser: boolean
+      - property: since
+        parser: duration
+`
+	c, err := Load(bytes.NewReader([]byte(y)))
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	if len(c.CustomCollectors) != 1 {
+		t.Fatalf("expected 1 custom collector, got %v", c.CustomCollectors)
+	}
+
+	cc := c.CustomCollectors[0]
+	expectedLabels := []CustomLabel{
+		{Property: "host"},
+		{Property: "comment", Label: "description"},
+	}
+	if len(cc.Labels) != len(expectedLabels) {
+		t.Fatalf("expected %d labels, got %v", len(expectedLabels), cc.Labels)
+	}
+
+	for i, l := range expectedLabels {
+		if cc.Labels[i] != l {
+			t.Fatalf("expected label %v, got %v", l, cc.Labels[i])
+		}
+	}
+
+	if cc.Labels[1].LabelName() != "description" {
+		t.Fatalf("expected label name description, got %s", cc.Labels[1].LabelName())
+	}
+
+	if cc.Metrics[0].MetricName() != "up" || cc.Metrics[1].MetricName() != "since" {
+		t.Fatalf("unexpected metric names %v", cc.Metrics)
+	}
+}
+
+func TestShouldFailOnInvalidCustomCollectors(t *testing.T) {
+	y := `
+devices:
+  - name: test1
+    address: 192.168.1.1
+    user: foo
+    password: bar
+
+custom_collectors:
+  - name: netwatch
+    command: tool/netwatch/print
+    filters:
+      - disabled=false
+    labels:
+      - name
+    metrics:
+      - property: status
+        type: histogram
+        parser: percent
+  - name: netwatch
+    command: /tool/netwatch/print
+  - name: bgp_session
+    command: /routing/bgp/session/print
+    metrics:
+      - property: info
+  - name: tool
+    command: /tool/netwatch/print
+    metrics:
+      - property: netwatch-up
+  - name: tool_netwatch
+    command: /tool/netwatch/print
+    metrics:
+      - property: up
+`
+	_, err := Load(bytes.NewReader([]byte(y)))
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	expected := []string{
+		"custom_collectors[0] (netwatch): command has to start with /",
+		"custom_collectors[0] (netwatch): filter disabled=false has to start with ?",
+		"custom_collectors[0] (netwatch): labels: duplicate or reserved label name name",
+		"custom_collectors[0] (netwatch): metrics: unknown type histogram of status",
+		"custom_collectors[0] (netwatch): metrics: unknown parser percent of status",
+		"custom_collectors[1] (netwatch): at least one metric is required",
+		"custom_collectors[1] (netwatch): duplicate collector name, already used by custom_collectors[0]",
+		"custom_collectors[2] (bgp_session): metrics: metric name mikrotik_bgp_session_info may collide with the built-in mikrotik_bgp_ metrics",
+		"custom_collectors[4] (tool_netwatch): metrics: metric name mikrotik_tool_netwatch_up is already used by custom_collectors[3]",
+	}
+	if len(verr.Problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), verr.Problems)
+	}
+
+	for i, p := range expected {
+		if verr.Problems[i] != p {
+			t.Fatalf("expected problem %q, got %q", p, verr.Problems[i])
+		}
+	}
+}
+
+func TestShouldParsePollInterval(t *testing.T) {
+	y := `
+devices:
+  - name: test1
+    address: 192.168.1.1
+    user: foo
+    password: bar
+    poll_interval: 90s
+  - name: test2
+    address: 192.168.2.1
+    user: foo
+    password: bar
+    poll_interval: -1m
+`
+	_, err := Load(bytes.NewReader([]byte(y)))
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Problems) != 1 || verr.Problems[0] != "devices[1] (test2): poll_interval must not be negative" {
+		t.Fatalf("expected negative poll_interval to be rejected, got %v", err)
+	}
+
+	c, err := Load(bytes.NewReader([]byte(strings.Replace(y, "-1m", "0s", 1))))
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	if c.Devices[0].PollInterval != 90*time.Second {
+		t.Fatalf("expected poll interval 90s, got %v", c.Devices[0].PollInterval)
+	}
+}
+
 func loadTestFile(t *testing.T) []byte {
 	b, err := ioutil.ReadFile("config.test.yml")
 	if err != nil {
@@ -0,0 +1,68 @@
+package config
+
+// CustomCollector represents a collector defined in the config, which turns
+// the reply of a single API command into metrics
+type CustomCollector struct {
+	Name    string         `yaml:"name"`
+	Command string         `yaml:"command"`
+	Filters []string       `yaml:"filters,omitempty"`
+	Labels  []CustomLabel  `yaml:"labels,omitempty"`
+	Metrics []CustomMetric `yaml:"metrics"`
+}
+
+// CustomLabel represents a property used as label. Labels can be given as the
+// property name only, if the label name is the same.
+type CustomLabel struct {
+	Property string `yaml:"property"`
+	Label    string `yaml:"label,omitempty"`
+}
+
+// CustomMetric represents a property exported as metric
+type CustomMetric struct {
+	Property string `yaml:"property"`
+	Name     string `yaml:"name,omitempty"`
+	Help     string `yaml:"help,omitempty"`
+	Type     string `yaml:"type,omitempty"`
+	Parser   string `yaml:"parser,omitempty"`
+}
+
+// metric types and value parsers of custom metrics
+const (
+	MetricTypeGauge   = "gauge"
+	MetricTypeCounter = "counter"
+
+	ParserNumber   = "number"
+	ParserDuration = "duration"
+	ParserBoolean  = "boolean"
+	ParserRate     = "rate"
+)
+
+// UnmarshalYAML allows labels to be given as property name only
+func (l *CustomLabel) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var property string
+	if err := unmarshal(&property); err == nil {
+		l.Property = property
+		return nil
+	}
+
+	type plain CustomLabel
+	return unmarshal((*plain)(l))
+}
+
+// LabelName returns the name of the label, which defaults to the property
+func (l *CustomLabel) LabelName() string {
+	if l.Label != "" {
+		return l.Label
+	}
+
+	return l.Property
+}
+
+// MetricName returns the name of the metric, which defaults to the property
+func (m *CustomMetric) MetricName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+
+	return m.Property
+}
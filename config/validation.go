@@ -0,0 +1,366 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ValidationError lists all problems found in a config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+// builtinCollectors are the names of the collectors shipped with the exporter,
+// which can not be used by custom collectors
+var builtinCollectors = map[string]bool{
+	"interface":       true,
+	"resource":        true,
+	"bgp":             true,
+	"routes":          true,
+	"routesv6":        true,
+	"dhcp":            true,
+	"dhcp-leases":     true,
+	"dhcpv6":          true,
+	"pool":            true,
+	"poolv6":          true,
+	"optics":          true,
+	"wlan-stations":   true,
+	"wlan-interfaces": true,
+	"monitor":         true,
+	"ipsec-peers":     true,
+	"ospf-neighbor":   true,
+	"firewall":        true,
+	"firewallv6":      true,
+	"conntrack":       true,
+	"health":          true,
+	"queue":           true,
+
+	// used for connection failures in mikrotik_scrape_errors_total
+	"connect": true,
+}
+
+// BuiltinMetricPrefixes are the prefixes of the metrics of the built-in
+// collectors and the exporter itself, following mikrotik_. Custom collectors
+// must not create metrics starting with them, as they could collide.
+var BuiltinMetricPrefixes = []string{
+	"bgp", "conntrack", "connection", "device", "dhcp", "dhcpv6", "exporter",
+	"firewall_rule", "health", "interface", "ip_pool", "ipsec_peers",
+	"last_successful_poll", "monitor", "optics", "ospf_interface", "ospf_lsa",
+	"ospf_neighbor", "poll", "queue_simple", "queue_tree", "routeros", "routes",
+	"scrape", "system", "wlan_interface", "wlan_station",
+}
+
+var metricNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addProblem(location, format string, args ...interface{}) {
+	v.problems = append(v.problems, location+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) validate(c *Config) {
+	v.validateTLS("tls", c.TLS)
+
+	for _, name := range credentialNames(c) {
+		p := c.Credentials[name]
+		location := "credentials." + name
+		v.validateCredential(location, &p.Credential)
+
+		for i, f := range p.Fallback {
+			v.validateCredential(fmt.Sprintf("%s.fallback[%d]", location, i), &f)
+		}
+	}
+
+	collectors := make(map[string]int)
+	// metrics maps the generated metric names to the custom collector, as
+	// different collector and metric names can generate the same name
+	metrics := make(map[string]int)
+	for i, cc := range c.CustomCollectors {
+		location := fmt.Sprintf("custom_collectors[%d]", i)
+		if cc.Name != "" {
+			location += fmt.Sprintf(" (%s)", cc.Name)
+		}
+		v.validateCustomCollector(location, &cc)
+
+		if first, found := collectors[cc.Name]; found && cc.Name != "" {
+			v.addProblem(location, "duplicate collector name, already used by custom_collectors[%d]", first)
+			continue
+		}
+		collectors[cc.Name] = i
+
+		if !validMetricName(cc.Name) {
+			continue
+		}
+
+		for _, m := range cc.Metrics {
+			if !validMetricName(m.MetricName()) {
+				continue
+			}
+
+			name := metricName(cc.Name) + "_" + metricName(m.MetricName())
+			if first, found := metrics[name]; found && first != i {
+				v.addProblem(location, "metrics: metric name mikrotik_%s is already used by custom_collectors[%d]", name, first)
+				continue
+			}
+			metrics[name] = i
+		}
+	}
+
+	names := make(map[string]int)
+	for i, d := range c.Devices {
+		location := deviceLocation(i, &d)
+		v.validateDevice(location, c, &d)
+
+		if d.Name == "" {
+			continue
+		}
+
+		if first, found := names[d.Name]; found {
+			v.addProblem(location, "duplicate device name, already used by devices[%d]", first)
+			continue
+		}
+		names[d.Name] = i
+	}
+}
+
+func credentialNames(c *Config) []string {
+	names := make([]string, 0, len(c.Credentials))
+	for name := range c.Credentials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func deviceLocation(i int, d *Device) string {
+	if d.Name == "" {
+		return fmt.Sprintf("devices[%d]", i)
+	}
+
+	return fmt.Sprintf("devices[%d] (%s)", i, d.Name)
+}
+
+func (v *validator) validateDevice(location string, c *Config, d *Device) {
+	if d.Name == "" {
+		v.addProblem(location, "name is required")
+	}
+
+	if d.Address == "" {
+		v.addProblem(location, "address is required")
+	}
+
+	if d.Credentials != "" {
+		if _, found := c.Credentials[d.Credentials]; !found {
+			v.addProblem(location, "unknown credentials %s", d.Credentials)
+		}
+
+		if d.User != "" || d.Password != "" || d.PasswordFile != "" || d.PasswordEnv != "" {
+			v.addProblem(location, "credentials can not be used together with user or password")
+		}
+	} else {
+		v.validateCredential(location, &Credential{
+			User:         d.User,
+			Password:     d.Password,
+			PasswordFile: d.PasswordFile,
+			PasswordEnv:  d.PasswordEnv,
+		})
+	}
+
+	if d.Port < 0 || d.Port > 65535 {
+		v.addProblem(location, "port %d is out of range", d.Port)
+	}
+
+	if d.PollInterval < 0 {
+		v.addProblem(location, "poll_interval must not be negative")
+	}
+
+	if d.Features != nil && d.FeatureProfile != "" {
+		v.addProblem(location, "features and feature_profile can not be used together")
+	}
+
+	if d.FeatureProfile != "" {
+		if _, found := c.FeatureProfiles[d.FeatureProfile]; !found {
+			v.addProblem(location, "unknown feature profile %s", d.FeatureProfile)
+		}
+	}
+
+	v.validateTLS(location+": tls", d.TLS)
+}
+
+func (v *validator) validateCredential(location string, cred *Credential) {
+	if cred.User == "" {
+		v.addProblem(location, "user is required")
+	}
+
+	passwords := 0
+	for _, p := range []string{cred.Password, cred.PasswordFile, cred.PasswordEnv} {
+		if p != "" {
+			passwords++
+		}
+	}
+
+	if passwords == 0 {
+		v.addProblem(location, "one of password, password_file or password_env is required")
+	} else if passwords > 1 {
+		v.addProblem(location, "only one of password, password_file or password_env can be used")
+	}
+}
+
+func (v *validator) validateCustomCollector(location string, cc *CustomCollector) {
+	if cc.Name == "" {
+		v.addProblem(location, "name is required")
+	} else if !validMetricName(cc.Name) {
+		v.addProblem(location, "invalid name %s", cc.Name)
+	} else if builtinCollectors[cc.Name] {
+		v.addProblem(location, "name %s is already used by a built-in collector", cc.Name)
+	}
+
+	if !strings.HasPrefix(cc.Command, "/") {
+		v.addProblem(location, "command has to start with /")
+	}
+
+	for _, f := range cc.Filters {
+		if !strings.HasPrefix(f, "?") {
+			v.addProblem(location, "filter %s has to start with ?", f)
+		}
+	}
+
+	labels := map[string]bool{"name": true, "address": true}
+	for _, l := range cc.Labels {
+		name := l.LabelName()
+		if l.Property == "" {
+			v.addProblem(location, "labels: property is required")
+		} else if !validMetricName(name) {
+			v.addProblem(location, "labels: invalid label name %s", name)
+		} else if labels[metricName(name)] {
+			v.addProblem(location, "labels: duplicate or reserved label name %s", name)
+		}
+		labels[metricName(name)] = true
+	}
+
+	if len(cc.Metrics) == 0 {
+		v.addProblem(location, "at least one metric is required")
+	}
+
+	metrics := make(map[string]bool)
+	for _, m := range cc.Metrics {
+		name := m.MetricName()
+		if m.Property == "" {
+			v.addProblem(location, "metrics: property is required")
+		} else if !validMetricName(name) {
+			v.addProblem(location, "metrics: invalid metric name %s", name)
+		} else if metrics[metricName(name)] {
+			v.addProblem(location, "metrics: duplicate metric name %s", name)
+		} else if prefix := builtinMetricPrefix(cc.Name, name); prefix != "" {
+			v.addProblem(location, "metrics: metric name mikrotik_%s_%s may collide with the built-in mikrotik_%s_ metrics", metricName(cc.Name), metricName(name), prefix)
+		}
+		metrics[metricName(name)] = true
+
+		switch m.Type {
+		case "", MetricTypeGauge, MetricTypeCounter:
+		default:
+			v.addProblem(location, "metrics: unknown type %s of %s", m.Type, name)
+		}
+
+		switch m.Parser {
+		case "", ParserNumber, ParserDuration, ParserBoolean, ParserRate:
+		default:
+			v.addProblem(location, "metrics: unknown parser %s of %s", m.Parser, name)
+		}
+	}
+}
+
+// metricName replaces the dashes used in RouterOS property names
+func metricName(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// builtinMetricPrefix returns the built-in prefix of the metric generated for
+// a custom collector, or an empty string if it does not use one
+func builtinMetricPrefix(collector, metric string) string {
+	name := metricName(collector) + "_" + metricName(metric)
+	for _, p := range BuiltinMetricPrefixes {
+		if strings.HasPrefix(name, p+"_") {
+			return p
+		}
+	}
+
+	return ""
+}
+
+func validMetricName(name string) bool {
+	return metricNameRegex.MatchString(metricName(name))
+}
+
+func (v *validator) validateTLS(location string, t *TLSConfig) {
+	if t == nil {
+		return
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		v.addProblem(location, "cert_file and key_file have to be set together")
+	}
+
+	if _, found := TLSVersions[t.MinVersion]; t.MinVersion != "" && !found {
+		v.addProblem(location, "unknown min_version %s", t.MinVersion)
+	}
+}
+
+// resolveCredentials reads passwords given by password_file or password_env
+// and applies credential profiles to devices. Passwords are read again on
+// every config reload.
+func (v *validator) resolveCredentials(c *Config) {
+	for _, name := range credentialNames(c) {
+		p := c.Credentials[name]
+		location := "credentials." + name
+		v.readPassword(location, &p.Credential)
+
+		fallback := make([]Credential, len(p.Fallback))
+		for i, f := range p.Fallback {
+			v.readPassword(fmt.Sprintf("%s.fallback[%d]", location, i), &f)
+			fallback[i] = f
+		}
+		p.Fallback = fallback
+
+		c.Credentials[name] = p
+	}
+
+	for i := range c.Devices {
+		d := &c.Devices[i]
+		if d.Credentials != "" {
+			p := c.Credentials[d.Credentials]
+			p.Apply(d)
+			continue
+		}
+
+		cred := Credential{
+			Password:     d.Password,
+			PasswordFile: d.PasswordFile,
+			PasswordEnv:  d.PasswordEnv,
+		}
+		v.readPassword(deviceLocation(i, d), &cred)
+		d.Password = cred.Password
+	}
+}
+
+func (v *validator) readPassword(location string, cred *Credential) {
+	if cred.PasswordFile == "" && cred.PasswordEnv == "" {
+		return
+	}
+
+	p, err := ReadPassword(cred.PasswordFile, cred.PasswordEnv)
+	if err != nil {
+		v.addProblem(location, "could not read password: %v", err)
+		return
+	}
+	cred.Password = p
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/prometheus/common/version"
 
@@ -18,21 +21,35 @@ import (
 	"mikrotik-exporter/config"
 )
 
+// probeTimeoutOffset is subtracted from the scrape timeout sent by Prometheus
+// to leave time for sending the response
+const probeTimeoutOffset = 500 * time.Millisecond
+
 // single device can be defined via CLI flags, multiple via config file.
 var (
-	address     = flag.String("address", "", "address of the device to monitor")
-	configFile  = flag.String("config-file", "", "config file to load")
-	device      = flag.String("device", "", "single device to monitor")
-	insecure    = flag.Bool("insecure", false, "skips verification of server certificate when using TLS (not recommended)")
-	logFormat   = flag.String("log-format", "json", "logformat text or json (default json)")
-	logLevel    = flag.String("log-level", "info", "log level")
-	metricsPath = flag.String("path", "/metrics", "path to answer requests on")
-	password    = flag.String("password", "", "password for authentication for single device")
-	port        = flag.String("port", ":9436", "port number to listen on")
-	timeout     = flag.Duration("timeout", collector.DefaultTimeout, "timeout when connecting to devices")
-	tls         = flag.Bool("tls", false, "use tls to connect to routers")
-	user        = flag.String("user", "", "user for authentication with single device")
-	ver         = flag.Bool("version", false, "find the version of binary")
+	address       = flag.String("address", "", "address of the device to monitor")
+	configFile    = flag.String("config-file", "", "config file to load")
+	device        = flag.String("device", "", "single device to monitor")
+	insecure      = flag.Bool("insecure", false, "skips verification of server certificate when using TLS (not recommended)")
+	logFormat     = flag.String("log-format", "json", "logformat text or json (default json)")
+	logLevel      = flag.String("log-level", "info", "log level")
+	maxConcurrent = flag.Int("max-concurrency", 0, "maximum number of devices collected at the same time, 0 disables the limit")
+	metricsPath   = flag.String("path", "/metrics", "path to answer requests on")
+	password      = flag.String("password", "", "password for authentication for single device")
+	passwordEnv   = flag.String("password-env", "", "environment variable containing the password for single device")
+	passwordFile  = flag.String("password-file", "", "file containing the password for single device")
+	pollInterval  = flag.Duration("poll-interval", 0, "interval to poll devices in the background, 0 collects devices on every scrape")
+	port          = flag.String("port", ":9436", "port number to listen on")
+	probePath     = flag.String("probe-path", "/probe", "path to answer probe requests for a single target on")
+	probeUnknown  = flag.Bool("probe-allow-unknown-targets", false, "allows probing targets which are not configured as a device, using the default credentials (not recommended)")
+	recordDir     = flag.String("record-dir", "", "directory to record all commands and replies per device to, passwords are redacted")
+	replayDir     = flag.String("replay-dir", "", "directory to replay recorded replies from instead of connecting to the devices")
+	scrapeJitter  = flag.Duration("scrape-jitter", 0, "maximum random delay before collecting a device, spreads connections to many devices")
+	scrapeTimeout = flag.Duration("scrape-timeout", 0, "maximum time to collect a single device, 0 disables the limit")
+	timeout       = flag.Duration("timeout", collector.DefaultTimeout, "timeout when connecting to devices and for each API command")
+	tls           = flag.Bool("tls", false, "use tls to connect to routers")
+	user          = flag.String("user", "", "user for authentication with single device")
+	ver           = flag.Bool("version", false, "find the version of binary")
 
 	withBgp          = flag.Bool("with-bgp", false, "retrieves BGP routing infrormation")
 	withRoutes       = flag.Bool("with-routes", false, "retrieves routing table(v4) information")
@@ -47,12 +64,22 @@ var (
 	withWlanIF       = flag.Bool("with-wlanif", false, "retrieves wlan interface metrics")
 	withMonitor      = flag.Bool("with-monitor", false, "retrieves ethernet interface monitor info")
 	withIPSecPeers   = flag.Bool("with-ipsec-peers", false, "retrieves ipsec peers info")
-	withOSPFNeighbor = flag.Bool("with-ospf-neighbor", false, "retrieves ospf neighbor info")
+	withOSPFNeighbor = flag.Bool("with-ospf-neighbor", false, "retrieves ospf neighbor, interface and lsa info")
+	withFirewall     = flag.Bool("with-firewall", false, "retrieves IP(v4) firewall rule counters")
+	withFirewallV6   = flag.Bool("with-firewallv6", false, "retrieves IP(v6) firewall rule counters")
+	withConntrack    = flag.Bool("with-conntrack", false, "retrieves connection tracking metrics")
+	withHealth       = flag.Bool("with-health", false, "retrieves system health metrics (temperatures, voltages, fans and power supplies)")
+	withQueue        = flag.Bool("with-queue", false, "retrieves simple and tree queue metrics")
 
-	cfg *config.Config
+	firewallCommentedOnly = flag.Bool("firewall-commented-only", false, "retrieves only firewall rules with a comment")
+	conntrackTopN         = flag.Int("conntrack-top-n", 0, "number of source addresses with the most connections to retrieve, 0 disables it")
 
 	appVersion = "DEVELOPMENT"
 	shortSha   = "0xDEADBEEF"
+
+	// limiter is shared by all collectors, so the limit applies to /metrics
+	// and /probe requests together
+	limiter *collector.Limiter
 )
 
 func init() {
@@ -60,6 +87,10 @@ func init() {
 }
 
 func main() {
+	if isCheckConfig() {
+		os.Exit(checkConfig(os.Args[2:], os.Stdout))
+	}
+
 	flag.Parse()
 
 	if *ver {
@@ -69,16 +100,44 @@ func main() {
 
 	configureLog()
 
+	if *maxConcurrent > 0 {
+		limiter = collector.NewLimiter(*maxConcurrent)
+	}
+
+	err := prepareRecording()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	c, err := loadConfig()
 	if err != nil {
 		log.Errorf("Could not load config: %v", err)
 		os.Exit(3)
 	}
-	cfg = c
+
+	err = applyConfig(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go reloadOnSignal()
 
 	startServer()
 }
 
+func prepareRecording() error {
+	if *recordDir == "" {
+		return nil
+	}
+
+	if *replayDir != "" {
+		return fmt.Errorf("record-dir and replay-dir can not be used together")
+	}
+
+	log.WithField("dir", *recordDir).Warn("recording all commands and replies")
+	return os.MkdirAll(*recordDir, 0700)
+}
+
 func configureLog() {
 	ll, err := log.ParseLevel(*logLevel)
 	if err != nil {
@@ -112,7 +171,21 @@ func loadConfigFromFile() (*config.Config, error) {
 }
 
 func loadConfigFromFlags() (*config.Config, error) {
-	if *device == "" || *address == "" || *user == "" || *password == "" {
+	pw, err := flagPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	if *user == "" || pw == "" {
+		return nil, fmt.Errorf("missing required param for single device configuration")
+	}
+
+	// credentials without a device only serve probe requests
+	if *device == "" && *address == "" {
+		return &config.Config{}, nil
+	}
+
+	if *device == "" || *address == "" {
 		return nil, fmt.Errorf("missing required param for single device configuration")
 	}
 
@@ -121,18 +194,33 @@ func loadConfigFromFlags() (*config.Config, error) {
 			Name:     *device,
 			Address:  *address,
 			User:     *user,
-			Password: *password,
+			Password: pw,
 		},
 		},
 	}, nil
 }
 
-func startServer() {
-	h, err := createMetricsHandler()
+// flagPassword returns the password given by the password flags. Passwords
+// read from a file or environment variable are read again on every call.
+func flagPassword() (string, error) {
+	if *password != "" || (*passwordFile == "" && *passwordEnv == "") {
+		return *password, nil
+	}
+
+	pw, err := config.ReadPassword(*passwordFile, *passwordEnv)
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("could not read password: %v", err)
 	}
-	http.Handle(*metricsPath, h)
+
+	return pw, nil
+}
+
+func startServer() {
+	http.Handle(*metricsPath, metricsHandler)
+
+	http.HandleFunc(*probePath, handleProbe)
+
+	http.HandleFunc("/-/reload", handleReload)
 
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("ok"))
@@ -144,6 +232,7 @@ func startServer() {
 			<body>
 			<h1>Mikrotik Exporter</h1>
 			<p><a href="` + *metricsPath + `">Metrics</a></p>
+			<p><a href="` + *probePath + `?target=">Probe</a></p>
 			</body>
 			</html>`))
 	})
@@ -152,14 +241,138 @@ func startServer() {
 	log.Fatal(http.ListenAndServe(*port, nil))
 }
 
-func createMetricsHandler() (http.Handler, error) {
-	opts := collectorOptions()
-	nc, err := collector.NewCollector(cfg, opts...)
+// createMetricsHandler creates the handler for the metrics path. Background
+// polling, if enabled, runs until ctx is done.
+func createMetricsHandler(ctx context.Context, c *config.Config) (http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	err := registry.Register(configReloadSuccess)
+	if err != nil {
+		return nil, err
+	}
+
+	err = registry.Register(configReloadSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"numDevices": len(c.Devices),
+	}).Info("setting up collector for devices")
+
+	opts := collectorOptions(c)
+	if *pollInterval > 0 {
+		opts = append(opts, collector.WithPolling(ctx, *pollInterval))
+	}
+
+	return createHandlerForRegistry(registry, c, opts...)
+}
+
+// handleProbe collects the metrics of a single device, given by name or address
+// in the target parameter, allowing Prometheus to scrape each device separately.
+func handleProbe(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	c := currentConfig()
+	d, err := deviceForTarget(c, target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	probeCfg := *c
+	probeCfg.Devices = []config.Device{d}
+
+	opts := collectorOptions(c)
+	if t := probeTimeout(r); t > 0 {
+		opts = append(opts, collector.WithScrapeTimeout(t))
+	}
+
+	h, err := createHandlerForRegistry(prometheus.NewRegistry(), &probeCfg, opts...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"target": target,
+			"error":  err,
+		}).Error("error creating probe handler")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.ServeHTTP(w, r)
+}
+
+// deviceForTarget looks up the target by name or address in the configured
+// devices. If allowed, unknown targets are treated as addresses and use the
+// default credential profile or the credentials given by the user and password
+// flags.
+func deviceForTarget(c *config.Config, target string) (config.Device, error) {
+	for _, d := range c.Devices {
+		if d.Name == target || d.Address == target {
+			return d, nil
+		}
+	}
+
+	// anyone able to reach the exporter could otherwise make it send the
+	// default credentials to a host of their choice
+	if !*probeUnknown {
+		return config.Device{}, fmt.Errorf("unknown target %q", target)
+	}
+
+	if p, found := c.Credentials[config.DefaultCredentials]; found {
+		d := config.Device{
+			Name:        target,
+			Address:     target,
+			Credentials: config.DefaultCredentials,
+		}
+		p.Apply(&d)
+
+		return d, nil
+	}
+
+	pw, err := flagPassword()
+	if err != nil {
+		return config.Device{}, err
+	}
+
+	if *user == "" || pw == "" {
+		return config.Device{}, fmt.Errorf("unknown target %q and no default credentials configured", target)
+	}
+
+	return config.Device{
+		Name:     target,
+		Address:  target,
+		User:     *user,
+		Password: pw,
+	}, nil
+}
+
+// probeTimeout returns the scrape timeout sent by Prometheus, leaving some time
+// to send the response. The scrape-timeout flag is used if it is lower.
+func probeTimeout(r *http.Request) time.Duration {
+	t := *scrapeTimeout
+
+	v, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil {
+		return t
+	}
+
+	pt := time.Duration(v*float64(time.Second)) - probeTimeoutOffset
+	if pt > 0 && (t == 0 || pt < t) {
+		return pt
+	}
+
+	return t
+}
+
+func createHandlerForRegistry(registry *prometheus.Registry, c *config.Config, opts ...collector.Option) (http.Handler, error) {
+	nc, err := collector.NewCollector(c, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	registry := prometheus.NewRegistry()
 	err = registry.Register(nc)
 	if err != nil {
 		return nil, err
@@ -172,7 +385,7 @@ func createMetricsHandler() (http.Handler, error) {
 		}), nil
 }
 
-func collectorOptions() []collector.Option {
+func collectorOptions(cfg *config.Config) []collector.Option {
 	opts := []collector.Option{}
 
 	if *withBgp || cfg.Features.BGP {
@@ -231,12 +444,59 @@ func collectorOptions() []collector.Option {
 		opts = append(opts, collector.WithOSPFNeighbor())
 	}
 
+	commentedOnly := *firewallCommentedOnly || cfg.Features.FirewallCommentedOnly
+	if *withFirewall || cfg.Features.Firewall {
+		opts = append(opts, collector.WithFirewall(commentedOnly))
+	}
+
+	if *withFirewallV6 || cfg.Features.FirewallV6 {
+		opts = append(opts, collector.WithFirewallV6(commentedOnly))
+	}
+
+	if *withConntrack || cfg.Features.Conntrack {
+		topN := cfg.Features.ConntrackTopN
+		if *conntrackTopN > 0 {
+			topN = *conntrackTopN
+		}
+		opts = append(opts, collector.WithConntrack(topN))
+	}
+
+	if *withHealth || cfg.Features.Health {
+		opts = append(opts, collector.WithHealth())
+	}
+
+	if *withQueue || cfg.Features.Queue {
+		opts = append(opts, collector.WithQueue())
+	}
+
 	if *timeout != collector.DefaultTimeout {
 		opts = append(opts, collector.WithTimeout(*timeout))
 	}
 
+	if *scrapeTimeout > 0 {
+		opts = append(opts, collector.WithScrapeTimeout(*scrapeTimeout))
+	}
+
 	if *tls {
 		opts = append(opts, collector.WithTLS(*insecure))
+	} else if *insecure {
+		opts = append(opts, collector.WithInsecureTLS())
+	}
+
+	if *recordDir != "" {
+		opts = append(opts, collector.WithRecordDir(*recordDir))
+	}
+
+	if *replayDir != "" {
+		opts = append(opts, collector.WithReplayDir(*replayDir))
+	}
+
+	if limiter != nil {
+		opts = append(opts, collector.WithLimiter(limiter))
+	}
+
+	if *scrapeJitter > 0 {
+		opts = append(opts, collector.WithJitter(*scrapeJitter))
 	}
 
 	return opts
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"mikrotik-exporter/config"
+)
+
+var (
+	configReloadSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mikrotik_exporter",
+		Name:      "config_last_reload_successful",
+		Help:      "Whether the last configuration reload attempt was successful.",
+	})
+	configReloadSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mikrotik_exporter",
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Timestamp of the last successful configuration reload.",
+	})
+
+	// cfg is the currently active config, replaced on every successful reload
+	cfg      *config.Config
+	cfgMu    sync.RWMutex
+	reloadMu sync.Mutex
+
+	metricsHandler = &reloadableHandler{}
+
+	// stopPolling stops the background polling of the active config
+	stopPolling context.CancelFunc = func() {}
+)
+
+// reloadableHandler serves requests with the handler created for the
+// currently active config
+type reloadableHandler struct {
+	mu      sync.RWMutex
+	handler http.Handler
+}
+
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	handler := h.handler
+	h.mu.RUnlock()
+
+	handler.ServeHTTP(w, r)
+}
+
+func (h *reloadableHandler) set(handler http.Handler) {
+	h.mu.Lock()
+	h.handler = handler
+	h.mu.Unlock()
+}
+
+func currentConfig() *config.Config {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+
+	return cfg
+}
+
+// applyConfig makes c the active config. Scrapes in progress finish with the
+// previous config, background polling of the previous config is stopped.
+func applyConfig(c *config.Config) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	h, err := createMetricsHandler(ctx, c)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	cfgMu.Lock()
+	cfg = c
+	cfgMu.Unlock()
+
+	metricsHandler.set(h)
+
+	stopPolling()
+	stopPolling = cancel
+
+	configReloadSuccess.Set(1)
+	configReloadSeconds.SetToCurrentTime()
+
+	return nil
+}
+
+// reloadConfig loads the config again. If the new config is invalid, the
+// previous config stays active.
+func reloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	c, err := loadConfig()
+	if err == nil {
+		err = applyConfig(c)
+	}
+
+	if err != nil {
+		configReloadSuccess.Set(0)
+		log.WithField("error", err).Error("error reloading config, keeping previous config")
+		return err
+	}
+
+	log.WithField("devices", len(c.Devices)).Info("config reloaded")
+	return nil
+}
+
+func reloadOnSignal() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		_ = reloadConfig()
+	}
+}
+
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := reloadConfig()
+	if err != nil {
+		http.Error(w, "failed to reload config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, _ = w.Write([]byte("ok"))
+}
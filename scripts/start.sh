@@ -4,9 +4,12 @@ if [ ! -x /app/mikrotik-exporter ]; then
   chmod 755 /app/mikrotik-expoter
 fi
 
-if [ -z "$CONFIG_FILE" ]
+if [ -n "$CONFIG_FILE" ]
 then
-    /app/mikrotik-exporter -device $DEVICE -address $ADDRESS -user $USER -password $PASSWORD
-else
     /app/mikrotik-exporter -config-file $CONFIG_FILE
+elif [ -n "$PASSWORD_FILE" ]
+then
+    /app/mikrotik-exporter -device $DEVICE -address $ADDRESS -user $USER -password-file $PASSWORD_FILE
+else
+    /app/mikrotik-exporter -device $DEVICE -address $ADDRESS -user $USER -password-env PASSWORD
 fi